@@ -0,0 +1,11 @@
+// Package api holds the OpenAPI 3 description of the HTTP API. The typed Go
+// client in package client is generated from it, so route changes must be
+// reflected in openapi.json.
+package api
+
+import _ "embed"
+
+// Spec is the OpenAPI document, served at /api/openapi.json
+//
+//go:embed openapi.json
+var Spec []byte
@@ -0,0 +1,81 @@
+// Package apierror defines the error envelope returned by every API endpoint:
+//
+//	{
+//		"error": {"code": "LIBRARY_NOT_FOUND", "message": "Library not found", "details": {"library": "x"}},
+//		"request_id": "3f2a9c0d1e4b5a68"
+//	}
+//
+// Codes are stable and meant for programs, messages are localized from the
+// Accept-Language header and meant for people.
+package apierror
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Error is an API error with a stable code. Details carries the values a
+// client needs to act on the error, Err the underlying cause for the log.
+type Error struct {
+	Code    Code
+	Details map[string]any
+	Err     error
+}
+
+// New returns an error with the given code
+func New(code Code) *Error {
+	return &Error{Code: code}
+}
+
+// Wrap returns an error with the given code caused by err
+func Wrap(code Code, err error) *Error {
+	return &Error{Code: code, Err: err}
+}
+
+// With adds a detail to the error and returns it for chaining
+func (e *Error) With(key string, value any) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]any)
+	}
+	e.Details[key] = value
+	return e
+}
+
+// Error returns the English message followed by the cause, for logs
+func (e *Error) Error() string {
+	msg := e.Code.Message("en")
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the HTTP status of the error code
+func (e *Error) Status() int {
+	return e.Code.Status()
+}
+
+// Body is the JSON shape of the "error" field
+type Body struct {
+	Code    Code           `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Abort writes the error envelope in the language requested by the client
+// and stops the handler chain
+func Abort(c *gin.Context, requestID string, e *Error) {
+	lang := Language(c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", lang)
+	c.AbortWithStatusJSON(e.Status(), gin.H{
+		"error": Body{
+			Code:    e.Code,
+			Message: e.Code.Message(lang),
+			Details: e.Details,
+		},
+		"request_id": requestID,
+	})
+}
@@ -0,0 +1,104 @@
+package apierror
+
+import "net/http"
+
+// Code identifies an error kind. Codes are part of the API and must not change.
+type Code string
+
+const (
+	InvalidRequest     Code = "INVALID_REQUEST"
+	Unauthorized       Code = "UNAUTHORIZED"
+	PayloadTooLarge    Code = "PAYLOAD_TOO_LARGE"
+	NotFound           Code = "NOT_FOUND"
+	Internal           Code = "INTERNAL_ERROR"
+	TransferIncomplete Code = "TRANSFER_INCOMPLETE"
+
+	LibraryRequired     Code = "LIBRARY_REQUIRED"
+	InvalidLibraryName  Code = "INVALID_LIBRARY_NAME"
+	LibraryNotFound     Code = "LIBRARY_NOT_FOUND"
+	LibraryExists       Code = "LIBRARY_EXISTS"
+	LibraryUnavailable  Code = "LIBRARY_UNAVAILABLE"
+	LibraryArchived     Code = "LIBRARY_ARCHIVED"
+	NotATemplate        Code = "NOT_A_TEMPLATE"
+	InvalidConfirmation Code = "INVALID_CONFIRMATION_TOKEN"
+
+	DocumentIDRequired Code = "DOCUMENT_ID_REQUIRED"
+	DocumentNotFound   Code = "DOCUMENT_NOT_FOUND"
+	ParentNotFound     Code = "PARENT_NOT_FOUND"
+	CycleDetected      Code = "CYCLE_DETECTED"
+	NoChanges          Code = "NO_CHANGES"
+	InvalidTag         Code = "INVALID_TAG"
+	MetaKeyInvalid     Code = "META_KEY_INVALID"
+	MetaValueInvalid   Code = "META_VALUE_INVALID"
+
+	ConfigKeyRequired     Code = "CONFIG_KEY_REQUIRED"
+	ConfigKeyUnknown      Code = "CONFIG_KEY_UNKNOWN"
+	ConfigKeyReadOnly     Code = "CONFIG_KEY_READ_ONLY"
+	ConfigValueInvalid    Code = "CONFIG_VALUE_INVALID"
+	InvalidConfigDocument Code = "INVALID_CONFIG_DOCUMENT"
+
+	FileRequired  Code = "FILE_REQUIRED"
+	ImageNotFound Code = "IMAGE_NOT_FOUND"
+)
+
+// definition is the HTTP status and the messages of a code by language
+type definition struct {
+	status   int
+	messages map[string]string
+}
+
+var definitions = map[Code]definition{
+	InvalidRequest:     {http.StatusBadRequest, map[string]string{"en": "Invalid request", "zh": "请求无效"}},
+	Unauthorized:       {http.StatusUnauthorized, map[string]string{"en": "Authentication required", "zh": "需要身份验证"}},
+	PayloadTooLarge:    {http.StatusRequestEntityTooLarge, map[string]string{"en": "Request body too large", "zh": "请求内容过大"}},
+	NotFound:           {http.StatusNotFound, map[string]string{"en": "Not found", "zh": "资源不存在"}},
+	Internal:           {http.StatusInternalServerError, map[string]string{"en": "Internal server error", "zh": "服务器内部错误"}},
+	TransferIncomplete: {http.StatusInternalServerError, map[string]string{"en": "Documents were copied but could not be removed from the source library", "zh": "文档已复制，但无法从源知识库删除"}},
+
+	LibraryRequired:     {http.StatusBadRequest, map[string]string{"en": "Library name is required", "zh": "缺少知识库名称"}},
+	InvalidLibraryName:  {http.StatusBadRequest, map[string]string{"en": "Invalid library directory name", "zh": "知识库目录名无效"}},
+	LibraryNotFound:     {http.StatusNotFound, map[string]string{"en": "Library not found", "zh": "知识库不存在"}},
+	LibraryExists:       {http.StatusConflict, map[string]string{"en": "Library already exists", "zh": "知识库已存在"}},
+	LibraryUnavailable:  {http.StatusConflict, map[string]string{"en": "Library is being renamed or deleted", "zh": "知识库正在重命名或删除"}},
+	LibraryArchived:     {http.StatusForbidden, map[string]string{"en": "Library is archived", "zh": "知识库已归档"}},
+	NotATemplate:        {http.StatusBadRequest, map[string]string{"en": "Library is not a template", "zh": "该知识库不是模板"}},
+	InvalidConfirmation: {http.StatusForbidden, map[string]string{"en": "Invalid or expired confirmation token", "zh": "确认令牌无效或已过期"}},
+
+	DocumentIDRequired: {http.StatusBadRequest, map[string]string{"en": "Document ID is required", "zh": "缺少文档 ID"}},
+	DocumentNotFound:   {http.StatusNotFound, map[string]string{"en": "Document not found", "zh": "文档不存在"}},
+	ParentNotFound:     {http.StatusNotFound, map[string]string{"en": "Parent document not found", "zh": "父文档不存在"}},
+	CycleDetected:      {http.StatusBadRequest, map[string]string{"en": "A document cannot be moved under itself", "zh": "不能将节点移动到自身下"}},
+	NoChanges:          {http.StatusBadRequest, map[string]string{"en": "No changes to apply", "zh": "没有需要更新的内容"}},
+	InvalidTag:         {http.StatusBadRequest, map[string]string{"en": "Invalid tag name", "zh": "标签名无效"}},
+	MetaKeyInvalid:     {http.StatusBadRequest, map[string]string{"en": "Invalid or unknown metadata key", "zh": "元数据键无效或未定义"}},
+	MetaValueInvalid:   {http.StatusBadRequest, map[string]string{"en": "Invalid metadata value", "zh": "元数据值无效"}},
+
+	ConfigKeyRequired:     {http.StatusBadRequest, map[string]string{"en": "Config name and key are required", "zh": "缺少配置名称或键"}},
+	ConfigKeyUnknown:      {http.StatusBadRequest, map[string]string{"en": "Unknown config key", "zh": "未知的配置项"}},
+	ConfigKeyReadOnly:     {http.StatusBadRequest, map[string]string{"en": "Config key is read-only", "zh": "配置项为只读"}},
+	ConfigValueInvalid:    {http.StatusBadRequest, map[string]string{"en": "Invalid config value", "zh": "配置值无效"}},
+	InvalidConfigDocument: {http.StatusBadRequest, map[string]string{"en": "Invalid config document", "zh": "配置文件格式无效"}},
+
+	FileRequired:  {http.StatusBadRequest, map[string]string{"en": "No file is received", "zh": "未收到文件"}},
+	ImageNotFound: {http.StatusNotFound, map[string]string{"en": "Image not found", "zh": "图片不存在"}},
+}
+
+// Status returns the HTTP status of the code, 500 for unknown codes
+func (c Code) Status() int {
+	if def, ok := definitions[c]; ok {
+		return def.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Message returns the message of the code in lang, falling back to English
+func (c Code) Message(lang string) string {
+	def, ok := definitions[c]
+	if !ok {
+		return Internal.Message(lang)
+	}
+	if msg, ok := def.messages[lang]; ok {
+		return msg
+	}
+	return def.messages[DefaultLanguage]
+}
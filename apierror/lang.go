@@ -0,0 +1,37 @@
+package apierror
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is used when the client accepts none of the supported languages
+const DefaultLanguage = "en"
+
+// supportedLanguages are the languages every message is translated to
+var supportedLanguages = map[string]bool{"en": true, "zh": true}
+
+// Language picks the supported language the client prefers from an
+// Accept-Language header such as "zh-CN,zh;q=0.9,en;q=0.8". Region subtags
+// are ignored, so zh-TW and zh-CN both get Chinese.
+func Language(header string) string {
+	best, bestQ := DefaultLanguage, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		// Earlier entries win ties, as clients list them in order of preference
+		if supportedLanguages[base] && q > bestQ {
+			best, bestQ = base, q
+		}
+	}
+	return best
+}
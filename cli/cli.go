@@ -0,0 +1,226 @@
+// Package cli implements the admin subcommands of the doc_admin binary. They
+// run against a document root on the local machine without a server: API
+// operations go through the regular router in process, file level operations
+// such as backups call the service package directly.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"main/client"
+	"main/config"
+	"main/router"
+	"main/service"
+)
+
+// command is a subcommand, possibly with subcommands of its own
+type command struct {
+	name        string
+	usage       string // Arguments, shown after the name
+	description string
+	run         func(env *env, args []string) error
+	subcommands []*command
+}
+
+var commands = []*command{
+	{name: "serve", usage: "[-config file] [-dir root] [-listen addr]", description: "Run the HTTP server (default)"},
+	{name: "library", description: "Manage libraries", subcommands: []*command{
+		{name: "create", usage: "[-name name] [-template dir] <dir>", description: "Create a library", run: libraryCreate},
+		{name: "list", usage: "[-archived]", description: "List libraries", run: libraryList},
+		{name: "rename", usage: "[-to dir] [-name name] <dir>", description: "Rename a library directory and/or display name", run: libraryRename},
+	}},
+	{name: "doc", description: "Read and write documents", subcommands: []*command{
+		{name: "get", usage: "-library dir [-content] <id>", description: "Print a document as JSON, or only its content", run: docGet},
+		{name: "put", usage: "-library dir [-id id] [-title title] [-parent id] [file|-]", description: "Create a document, or update it when -id is set, with content from a file or stdin", run: docPut},
+		{name: "mv", usage: "-library dir [-to library] <id> <parent>", description: "Move a document under another parent, optionally into another library", run: docMove},
+	}},
+	{name: "export", usage: "-library dir [-resolve-links] [-o file]", description: "Export the documents and config of a library as JSON", run: exportLibrary},
+	{name: "import", usage: "-library dir [-parent id] [-template] [file|-]", description: "Import documents and config exported from another library, undone if it fails", run: importLibrary},
+	{name: "backup", usage: "[-o dir] [library...]", description: "Write a .tar.gz snapshot of each library, all by default", run: backup},
+	{name: "migrate", usage: "[library...]", description: "Apply pending schema migrations, to all libraries by default", run: migrate},
+	{name: "fsck", usage: "[-repair] [library...]", description: "Check libraries for corruption, broken trees and links and stray images, all by default", run: fsck},
+}
+
+// IsCommand reports whether name is a subcommand handled by Run
+func IsCommand(name string) bool {
+	for _, cmd := range commands {
+		if cmd.name == name && cmd.name != "serve" {
+			return true
+		}
+	}
+	return name == "help" || name == "-h" || name == "--help"
+}
+
+// Run executes a subcommand and returns the process exit status
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stdout, "", commands)
+		return 0
+	}
+
+	cmds, path := commands, ""
+	for {
+		cmd := findCommand(cmds, args[0])
+		if cmd == nil {
+			fmt.Fprintf(stderr, "unknown command %q\n\n", strings.TrimSpace(path+" "+args[0]))
+			printUsage(stderr, path, cmds)
+			return 2
+		}
+		path = strings.TrimSpace(path + " " + cmd.name)
+		args = args[1:]
+
+		if cmd.run != nil {
+			env := &env{name: path, usage: cmd.usage, stdin: stdin, stdout: stdout, stderr: stderr}
+			err := cmd.run(env, args)
+			switch {
+			case err == nil:
+				return 0
+			case errors.Is(err, flag.ErrHelp):
+				return 0
+			case errors.Is(err, errUsage):
+				return 2
+			default:
+				fmt.Fprintf(stderr, "%s: %v\n", path, err)
+				return 1
+			}
+		}
+
+		if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+			printUsage(stdout, path, cmd.subcommands)
+			return 0
+		}
+		cmds = cmd.subcommands
+	}
+}
+
+func findCommand(cmds []*command, name string) *command {
+	for _, cmd := range cmds {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func printUsage(w io.Writer, path string, cmds []*command) {
+	prefix := "doc_admin "
+	if path != "" {
+		prefix += path + " "
+	}
+	fmt.Fprintf(w, "Usage: %s<command> [flags]\n\nCommands:\n", prefix)
+	for _, cmd := range cmds {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintln(w, "\nEvery command accepts -config and -dir like the server. Run a command with -h for its flags.")
+}
+
+// errUsage is returned after a usage message was printed for bad arguments
+var errUsage = errors.New("usage")
+
+// env carries the streams and the common flags of a running command
+type env struct {
+	name   string
+	usage  string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	configPath string
+	docRoot    string
+	flags      *flag.FlagSet
+	cfg        *config.Config
+}
+
+// flagSet returns a flag set for the command that already holds the common
+// -config and -dir flags
+func (e *env) flagSet() *flag.FlagSet {
+	e.flags = flag.NewFlagSet(e.name, flag.ContinueOnError)
+	e.flags.SetOutput(e.stderr)
+	e.flags.StringVar(&e.configPath, "config", config.DefaultPath, "Configuration file path")
+	e.flags.StringVar(&e.docRoot, "dir", "", "Document root directory path, overrides the config")
+	e.flags.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: doc_admin %s %s\n\nFlags:\n", e.name, e.usage)
+		e.flags.PrintDefaults()
+	}
+	return e.flags
+}
+
+// parse parses the flags and checks the number of positional arguments
+func (e *env) parse(args []string, minArgs, maxArgs int) error {
+	if err := e.flags.Parse(args); err != nil {
+		return err
+	}
+	if n := e.flags.NArg(); n < minArgs || (maxArgs >= 0 && n > maxArgs) {
+		e.flags.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// config loads the configuration the same way the server does
+func (e *env) config() (*config.Config, error) {
+	if e.cfg != nil {
+		return e.cfg, nil
+	}
+	explicit := false
+	e.flags.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			explicit = true
+		}
+	})
+	cfg, err := config.Load(e.configPath, explicit)
+	if err != nil {
+		return nil, err
+	}
+	if e.docRoot != "" {
+		cfg.DocRoot = e.docRoot
+	}
+	e.cfg = cfg
+	return cfg, nil
+}
+
+// client returns an API client that calls the router in process, so every
+// operation goes through the same handlers as on the server
+func (e *env) client() (*client.Client, error) {
+	cfg, err := e.config()
+	if err != nil {
+		return nil, err
+	}
+
+	// The caller already has access to the files, and only failures are of interest
+	local := *cfg
+	local.Auth = config.AuthConfig{Type: "none"}
+	local.Log.Level = "error"
+	slog.SetDefault(slog.New(slog.NewTextHandler(e.stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+
+	hc := &http.Client{Transport: handlerTransport{handler: router.New(&local)}}
+	return client.New("http://doc_admin.local", client.WithHTTPClient(hc)), nil
+}
+
+// libraryService returns the library service on the configured document
+// root, for the maintenance commands that have no API endpoint
+func (e *env) libraryService() (service.LibraryService, error) {
+	cfg, err := e.config()
+	if err != nil {
+		return nil, err
+	}
+	return service.NewLibraryService(cfg.DocRoot), nil
+}
+
+// libraries returns the given library names, or all libraries when none are given
+func (e *env) libraries(names []string) ([]string, error) {
+	if len(names) > 0 {
+		return names, nil
+	}
+	libraries, err := e.libraryService()
+	if err != nil {
+		return nil, err
+	}
+	return libraries.Dirs()
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"main/client"
+)
+
+func docGet(e *env, args []string) error {
+	flags := e.flagSet()
+	library := flags.String("library", "", "Library directory")
+	contentOnly := flags.Bool("content", false, "Print only the content")
+	if err := e.parse(args, 1, 1); err != nil {
+		return err
+	}
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	doc, err := c.GetDocument(context.Background(), *library, id)
+	if err != nil {
+		return err
+	}
+
+	if *contentOnly {
+		_, err = io.WriteString(e.stdout, doc.Content)
+		return err
+	}
+	return writeJSON(e.stdout, doc)
+}
+
+func docPut(e *env, args []string) error {
+	flags := e.flagSet()
+	library := flags.String("library", "", "Library directory")
+	id := flags.Int64("id", 0, "Document to update, a new document is created when 0")
+	title := flags.String("title", "", "Title, left unchanged on update when empty")
+	parent := flags.Int64("parent", 0, "Parent of a new document, 0 for the root")
+	if err := e.parse(args, 0, 1); err != nil {
+		return err
+	}
+	content, err := readInput(e, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if *id == 0 {
+		res, err := c.CreateDocument(ctx, *library, client.CreateDocumentRequest{Title: *title, Content: string(content), ParentID: *parent})
+		if err != nil {
+			return err
+		}
+		warnBrokenLinks(e, res.BrokenLinks)
+		fmt.Fprintln(e.stdout, res.ID)
+		return nil
+	}
+
+	text := string(content)
+	res, err := c.UpdateDocument(ctx, *library, client.UpdateDocumentRequest{ID: *id, Title: *title, Content: &text})
+	if err != nil {
+		return err
+	}
+	warnBrokenLinks(e, res.BrokenLinks)
+	fmt.Fprintln(e.stdout, *id)
+	return nil
+}
+
+// warnBrokenLinks reports links of a saved document to missing documents
+func warnBrokenLinks(e *env, links []client.Link) {
+	for _, link := range links {
+		fmt.Fprintf(e.stderr, "warning: document %d does not exist\n", link.Target)
+	}
+}
+
+func docMove(e *env, args []string) error {
+	flags := e.flagSet()
+	library := flags.String("library", "", "Library directory")
+	to := flags.String("to", "", "Move the document and its children into this library")
+	if err := e.parse(args, 2, 2); err != nil {
+		return err
+	}
+	id, err := parseID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	parent, err := strconv.ParseInt(flags.Arg(1), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid parent ID %q", flags.Arg(1))
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if *to != "" && *to != *library {
+		res, err := c.TransferDocument(ctx, client.TransferRequest{
+			SourceLibrary: *library,
+			TargetLibrary: *to,
+			ID:            id,
+			ParentID:      parent,
+			Mode:          "move",
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(e.stdout, "moved %d documents to %s, new ID %d\n", len(res.IDs), *to, res.ID)
+		return nil
+	}
+
+	if _, err := c.UpdateDocumentParent(ctx, *library, client.MoveDocumentRequest{ID: id, ParentID: parent}); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "moved %d under %d\n", id, parent)
+	return nil
+}
+
+// parseID parses a positive document ID argument
+func parseID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid document ID %q", s)
+	}
+	return id, nil
+}
+
+// readInput reads a file, or stdin when path is empty or "-"
+func readInput(e *env, path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(e.stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
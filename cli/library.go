@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"main/client"
+)
+
+func libraryCreate(e *env, args []string) error {
+	flags := e.flagSet()
+	name := flags.String("name", "", "Display name, defaults to the directory")
+	template := flags.String("template", "", "Directory of a template library to copy")
+	if err := e.parse(args, 1, 1); err != nil {
+		return err
+	}
+	dir := flags.Arg(0)
+	if *name == "" {
+		*name = dir
+	}
+
+	cfg, err := e.config()
+	if err != nil {
+		return err
+	}
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	res, err := c.CreateLibrary(context.Background(), client.CreateLibraryRequest{
+		Name:     *name,
+		BasePath: filepath.Join(cfg.DocRoot, dir),
+		Template: *template,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "created library %s at %s\n", res.Name, res.Path)
+	return nil
+}
+
+func libraryList(e *env, args []string) error {
+	flags := e.flagSet()
+	archived := flags.Bool("archived", false, "Include archived libraries")
+	if err := e.parse(args, 0, 0); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	res, err := c.ListLibraries(context.Background(), &client.ListLibrariesParams{Archived: *archived})
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIR\tNAME\tFLAGS")
+	for _, lib := range res.Libraries {
+		var flags []string
+		if lib.Archived == "true" {
+			flags = append(flags, "archived")
+		}
+		if lib.Template == "true" {
+			flags = append(flags, "template")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", lib.Dir, lib.Name, strings.Join(flags, ","))
+	}
+	return tw.Flush()
+}
+
+func libraryRename(e *env, args []string) error {
+	flags := e.flagSet()
+	to := flags.String("to", "", "New directory name")
+	name := flags.String("name", "", "New display name")
+	if err := e.parse(args, 1, 1); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	res, err := c.RenameLibrary(context.Background(), flags.Arg(0), client.RenameLibraryRequest{Dir: *to, Name: *name})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "renamed library %s to %s\n", flags.Arg(0), res.Dir)
+	return nil
+}
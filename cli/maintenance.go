@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"main/service"
+)
+
+func backup(e *env, args []string) error {
+	flags := e.flagSet()
+	output := flags.String("o", ".", "Directory to write the archives to")
+	if err := e.parse(args, 0, -1); err != nil {
+		return err
+	}
+	libraryService, err := e.libraryService()
+	if err != nil {
+		return err
+	}
+	libraries, err := e.libraries(flags.Args())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*output, 0755); err != nil {
+		return err
+	}
+
+	stamp := time.Now().Format("20060102-150405")
+	failed := 0
+	for _, name := range libraries {
+		path := filepath.Join(*output, fmt.Sprintf("%s-%s.tar.gz", name, stamp))
+		if err := backupLibrary(libraryService, name, path); err != nil {
+			fmt.Fprintf(e.stderr, "%s: %v\n", name, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(e.stdout, "%s: %s\n", name, path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d libraries failed", failed, len(libraries))
+	}
+	return nil
+}
+
+// backupLibrary writes the archive of one library, removing it again on failure
+func backupLibrary(libraries service.LibraryService, name, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := libraries.Backup(context.Background(), name, f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+func migrate(e *env, args []string) error {
+	e.flagSet()
+	if err := e.parse(args, 0, -1); err != nil {
+		return err
+	}
+	libraryService, err := e.libraryService()
+	if err != nil {
+		return err
+	}
+	libraries, err := e.libraries(e.flags.Args())
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, name := range libraries {
+		from, to, err := libraryService.Migrate(context.Background(), name)
+		switch {
+		case err != nil:
+			fmt.Fprintf(e.stderr, "%s: %v\n", name, err)
+			failed++
+		case from == to:
+			fmt.Fprintf(e.stdout, "%s: up to date at version %d\n", name, to)
+		default:
+			fmt.Fprintf(e.stdout, "%s: migrated from version %d to %d\n", name, from, to)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d libraries failed", failed, len(libraries))
+	}
+	return nil
+}
+
+func fsck(e *env, args []string) error {
+	flags := e.flagSet()
+	repair := flags.Bool("repair", false, "Move orphaned, self-parented and cyclic documents to the root")
+	if err := e.parse(args, 0, -1); err != nil {
+		return err
+	}
+	libraryService, err := e.libraryService()
+	if err != nil {
+		return err
+	}
+	libraries, err := e.libraries(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, name := range libraries {
+		issues, err := libraryService.Check(context.Background(), name, *repair)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "%s: %v\n", name, err)
+			failed++
+			continue
+		}
+		remaining := 0
+		for _, issue := range issues {
+			fmt.Fprintf(e.stdout, "%s: %s\n", name, issue)
+			if !issue.Repaired {
+				remaining++
+			}
+		}
+		if remaining > 0 {
+			failed++
+		} else if len(issues) == 0 {
+			fmt.Fprintf(e.stdout, "%s: ok\n", name)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d libraries have problems", failed, len(libraries))
+	}
+	return nil
+}
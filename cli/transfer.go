@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"main/client"
+	"main/store"
+)
+
+// libraryExport is the file format of export and import
+type libraryExport struct {
+	Library   string                `json:"library"`
+	Config    client.ConfigDocument `json:"config"`
+	Documents []client.Document     `json:"documents"`
+}
+
+func exportLibrary(e *env, args []string) error {
+	flags := e.flagSet()
+	library := flags.String("library", "", "Library directory")
+	output := flags.String("o", "-", "Output file, stdout when -")
+	resolveLinks := flags.Bool("resolve-links", false, "Replace [[Page Title]] and [[#id]] links with /document?id= links")
+	if err := e.parse(args, 0, 0); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	config, err := c.ExportLibraryConfig(ctx, *library, nil)
+	if err != nil {
+		return err
+	}
+	docs, err := exportDocuments(ctx, c, *library)
+	if err != nil {
+		return err
+	}
+
+	if *resolveLinks {
+		rendered, err := c.RenderLibrary(ctx, *library)
+		if err != nil {
+			return err
+		}
+		byID := make(map[int64]client.RenderedDocument, len(rendered.Documents))
+		for _, doc := range rendered.Documents {
+			byID[doc.ID] = doc
+		}
+		for i, doc := range docs {
+			// Documents created since the list are left out of the export
+			if r, ok := byID[doc.ID]; ok {
+				docs[i].Content = r.Content
+				warnWikiLinks(e, doc.ID, r.WikiLinks)
+			}
+		}
+	}
+
+	export := libraryExport{Library: *library, Config: config, Documents: docs}
+	if *output == "-" {
+		return writeJSON(e.stdout, export)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(f, export); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exportDocuments pages through every document of a library with its tags
+// and metadata, which the tree leaves out
+func exportDocuments(ctx context.Context, c *client.Client, library string) ([]client.Document, error) {
+	docs := []client.Document{}
+	params := &client.ListDocumentsParams{Limit: 500, Fields: "id,title,content,parent_id,created_at,updated_at,tags,meta"}
+	for {
+		page, err := c.ListDocuments(ctx, library, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range page.Documents {
+			docs = append(docs, client.Document{
+				ID:        doc.ID,
+				Title:     doc.Title,
+				Content:   doc.Content,
+				ParentID:  doc.ParentID,
+				CreatedAt: doc.CreatedAt,
+				UpdatedAt: doc.UpdatedAt,
+				Tags:      doc.Tags,
+				Meta:      doc.Meta,
+			})
+		}
+		if page.NextCursor == "" {
+			return docs, nil
+		}
+		params.Cursor = page.NextCursor
+	}
+}
+
+// undoImport deletes the top-level documents an import created together with
+// their children and restores the config the library had before
+func undoImport(ctx context.Context, c *client.Client, library string, config client.ConfigDocument, roots []int64) error {
+	if len(roots) > 0 {
+		ops := make([]client.DocumentOperation, len(roots))
+		for i, id := range roots {
+			ops[i] = client.DocumentOperation{Op: "delete", ID: id}
+		}
+		if _, err := c.BatchDocuments(ctx, library, client.DocumentBatchRequest{Operations: ops}); err != nil {
+			return fmt.Errorf("documents: %w", err)
+		}
+	}
+	if _, err := c.ImportLibraryConfig(ctx, library, &client.ImportLibraryConfigParams{Replace: true}, config); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	return nil
+}
+
+// warnWikiLinks reports the wiki links of a document that could not be resolved
+func warnWikiLinks(e *env, id int64, links []client.WikiLink) {
+	for _, link := range links {
+		switch link.Status {
+		case "unresolved":
+			fmt.Fprintf(e.stderr, "warning: document %d: [[%s]] matches no document\n", id, link.Target)
+		case "ambiguous":
+			fmt.Fprintf(e.stderr, "warning: document %d: [[%s]] matches documents %v\n", id, link.Target, link.Candidates)
+		}
+	}
+}
+
+func importLibrary(e *env, args []string) (err error) {
+	flags := e.flagSet()
+	library := flags.String("library", "", "Library directory to import into")
+	parent := flags.Int64("parent", 0, "Parent of the imported top-level documents, 0 for the root")
+	template := flags.Bool("template", false, "Keep the template marker of the exported library")
+	if err := e.parse(args, 0, 1); err != nil {
+		return err
+	}
+	data, err := readInput(e, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var export libraryExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("invalid export file: %w", err)
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	// A failed import is undone, so the library is left as it was and the
+	// import can simply be retried
+	before, err := c.ExportLibraryConfig(ctx, *library, nil)
+	if err != nil {
+		return err
+	}
+	var roots []int64
+	defer func() {
+		if err != nil {
+			if undoErr := undoImport(ctx, c, *library, before, roots); undoErr != nil {
+				fmt.Fprintf(e.stderr, "undoing the import failed: %v\n", undoErr)
+			}
+		}
+	}()
+
+	// The config goes first, it holds the metadata schema the documents
+	// are validated against
+	applied := 0
+	if len(export.Config) > 0 {
+		// The display name belongs to the target library, and it only
+		// becomes a template when asked to
+		delete(export.Config["blog"], "name")
+		if !*template {
+			delete(export.Config["blog"], "template")
+		}
+		res, err := c.ImportLibraryConfig(ctx, *library, nil, export.Config)
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		applied = res.Applied
+	}
+
+	// Create parents before their children so every parent ID can be remapped.
+	// Documents whose parent is not part of the export become top-level.
+	known := make(map[int64]bool, len(export.Documents))
+	children := make(map[int64][]client.Document)
+	for _, doc := range export.Documents {
+		known[doc.ID] = true
+	}
+	var queue []client.Document
+	for _, doc := range export.Documents {
+		if known[doc.ParentID] && doc.ParentID != doc.ID {
+			children[doc.ParentID] = append(children[doc.ParentID], doc)
+		} else {
+			queue = append(queue, doc)
+		}
+	}
+
+	idMap := make(map[int64]int64, len(export.Documents))
+	for i := 0; i < len(queue); i++ {
+		doc := queue[i]
+		parentID, top := *parent, true
+		if mapped, ok := idMap[doc.ParentID]; ok && known[doc.ParentID] {
+			parentID, top = mapped, false
+		}
+		res, err := c.CreateDocument(ctx, *library, client.CreateDocumentRequest{
+			Title:    doc.Title,
+			Content:  doc.Content,
+			ParentID: parentID,
+			Tags:     doc.Tags,
+			Meta:     doc.Meta,
+		})
+		if err != nil {
+			return fmt.Errorf("document %d: %w", doc.ID, err)
+		}
+		idMap[doc.ID] = res.ID
+		if top {
+			roots = append(roots, res.ID)
+		}
+		queue = append(queue, children[doc.ID]...)
+	}
+
+	// Now that all new IDs are known, document and [[#id]] wiki links
+	// between the imported documents follow them, including the links of
+	// exports with resolved wiki links. Links to documents that were not
+	// exported are left as they are.
+	target := func(id int64) (store.LinkTarget, bool) {
+		newID, ok := idMap[id]
+		return store.LinkTarget{ID: newID}, ok
+	}
+	var relink []client.DocumentOperation
+	for _, doc := range export.Documents {
+		newID, ok := idMap[doc.ID]
+		if !ok {
+			continue
+		}
+		content := store.RewriteWikiLinks(store.RewriteLinks(doc.Content, target), target)
+		if content != doc.Content {
+			relink = append(relink, client.DocumentOperation{Op: "update", ID: newID, Content: &content})
+		}
+	}
+	if len(relink) > 0 {
+		if _, err := c.BatchDocuments(ctx, *library, client.DocumentBatchRequest{Operations: relink}); err != nil {
+			return fmt.Errorf("links: %w", err)
+		}
+	}
+
+	if skipped := len(export.Documents) - len(idMap); skipped > 0 {
+		fmt.Fprintf(e.stderr, "skipped %d documents that are part of a parent cycle\n", skipped)
+	}
+	fmt.Fprintf(e.stdout, "imported %d documents and %d config values into %s\n", len(idMap), applied, *library)
+	return nil
+}
@@ -0,0 +1,171 @@
+// Package client is a typed Go client for the doc_admin HTTP API. The request
+// and response types and one method per endpoint are generated from
+// api/openapi.json into client.gen.go; this file holds the transport.
+package client
+
+//go:generate go run ../tools/genclient -spec ../api/openapi.json -out client.gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls the API of a single server
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	editors    []RequestEditor
+}
+
+// RequestEditor is called on every request before it is sent
+type RequestEditor func(req *http.Request) error
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRequestEditor adds a function that can modify every request, e.g. to set headers
+func WithRequestEditor(editor RequestEditor) Option {
+	return func(c *Client) { c.editors = append(c.editors, editor) }
+}
+
+// WithBasicAuth authenticates with a username and password
+func WithBasicAuth(username, password string) Option {
+	return WithRequestEditor(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// WithToken authenticates with a bearer token
+func WithToken(token string) Option {
+	return WithRequestEditor(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// New returns a client for the server at baseURL, e.g. "http://localhost:8080"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{baseURL: strings.TrimSuffix(baseURL, "/"), httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is returned for every response with a non-2xx status. Code is one of
+// the stable error codes of the API, such as LIBRARY_NOT_FOUND.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    map[string]any
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("doc_admin: HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("doc_admin: %s: %s (request %s)", e.Code, e.Message, e.RequestID)
+}
+
+// doJSON sends in as a JSON body when it is not nil and decodes the response into out
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+	return c.do(ctx, method, path, query, contentType, body, out)
+}
+
+// doMultipart uploads file as the "file" field of a multipart form
+func (c *Client) doMultipart(ctx context.Context, method, path string, query url.Values, filename string, file io.Reader, out any) error {
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+	return c.do(ctx, method, path, query, form.FormDataContentType(), &buf, out)
+}
+
+// do sends the request and decodes the response. out may be a *[]byte to
+// receive the raw body, nil to discard it, or any value to decode JSON into.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader, out any) error {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, edit := range c.editors {
+		if err := edit(req); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return decodeError(resp)
+	}
+
+	switch out := out.(type) {
+	case nil:
+		_, err = io.Copy(io.Discard, resp.Body)
+	case *[]byte:
+		*out, err = io.ReadAll(resp.Body)
+	default:
+		err = json.NewDecoder(resp.Body).Decode(out)
+	}
+	return err
+}
+
+// decodeError reads the error envelope of a failed response
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("X-Request-ID")}
+	var envelope ErrorEnvelope
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err == nil {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+		apiErr.Details = envelope.Error.Details
+		if envelope.RequestID != "" {
+			apiErr.RequestID = envelope.RequestID
+		}
+	}
+	return apiErr
+}
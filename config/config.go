@@ -0,0 +1,347 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of all environment variables that override the config file
+const EnvPrefix = "DOC_ADMIN_"
+
+// Config holds the server settings. Values are loaded from the YAML file
+// first, then overridden by environment variables and finally by flags.
+type Config struct {
+	DocRoot  string         `yaml:"doc_root"`
+	Listen   string         `yaml:"listen"`
+	Server   ServerConfig   `yaml:"server"`
+	TLS      TLSConfig      `yaml:"tls"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Upload   UploadConfig   `yaml:"upload"`
+	Log      LogConfig      `yaml:"log"`
+	Frontend FrontendConfig `yaml:"frontend"`
+	Health   HealthConfig   `yaml:"health"`
+}
+
+// ServerConfig holds the HTTP server timeouts, written as durations like "30s"
+type ServerConfig struct {
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"` // How long to wait for in-flight requests on shutdown
+}
+
+// TLSConfig enables HTTPS when both files are set. The files are reloaded
+// when they change on disk.
+type TLSConfig struct {
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	RedirectListen string `yaml:"redirect_listen"` // Optional plain HTTP address that redirects to HTTPS
+}
+
+// Enabled reports whether the server should serve HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// AuthConfig protects the API. Type is "none", "basic" or "token".
+type AuthConfig struct {
+	Type   string            `yaml:"type"`
+	Users  map[string]string `yaml:"users"`  // username -> password, for basic auth
+	Tokens []string          `yaml:"tokens"` // accepted bearer tokens, for token auth
+}
+
+// UploadConfig limits image uploads
+type UploadConfig struct {
+	MaxSize int64 `yaml:"max_size"` // Maximum upload size in bytes
+}
+
+// LogConfig controls the structured server log
+type LogConfig struct {
+	Level  string `yaml:"level"`  // "debug", "info", "warn" or "error"
+	Format string `yaml:"format"` // "text" or "json"
+	File   string `yaml:"file"`   // Log file path, stdout if empty
+}
+
+// FrontendConfig serves the web admin UI at / from a directory or from the
+// bundle embedded at build time. Dir takes precedence over Embedded.
+type FrontendConfig struct {
+	Dir      string `yaml:"dir"`
+	Embedded bool   `yaml:"embedded"`
+}
+
+// HealthConfig tunes the /readyz checks
+type HealthConfig struct {
+	MinFreeBytes         int64         `yaml:"min_free_bytes"`         // Minimum free disk space under doc_root
+	LibraryCheckInterval time.Duration `yaml:"library_check_interval"` // How long a library quick_check result is reused
+}
+
+// Default returns the configuration used when nothing else is specified
+func Default() *Config {
+	return &Config{
+		DocRoot: ".",
+		Listen:  ":8080",
+		Server: ServerConfig{
+			ReadTimeout:       60 * time.Second,
+			ReadHeaderTimeout: 10 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Auth:   AuthConfig{Type: "none"},
+		Upload: UploadConfig{MaxSize: 32 << 20},
+		Health: HealthConfig{MinFreeBytes: 100 << 20, LibraryCheckInterval: time.Minute},
+		Log:    LogConfig{Level: "info", Format: "json"},
+	}
+}
+
+// LoadFile reads a YAML config file on top of the defaults. A missing file is
+// only an error when required is true.
+func LoadFile(path string, required bool) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// DefaultPath is the config file read when no other path is given
+const DefaultPath = "config.yaml"
+
+// Load reads the config file and applies the environment on top. Without an
+// explicit path, DOC_ADMIN_CONFIG or DefaultPath is used, and a missing
+// DefaultPath is not an error.
+func Load(path string, explicit bool) (*Config, error) {
+	if v, ok := os.LookupEnv(EnvPrefix + "CONFIG"); ok && !explicit {
+		path = v
+	}
+	cfg, err := LoadFile(path, explicit || path != DefaultPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		return nil, fmt.Errorf("invalid environment: %w", err)
+	}
+	return cfg, nil
+}
+
+// ApplyEnv overrides settings from DOC_ADMIN_* environment variables
+func (c *Config) ApplyEnv() error {
+	lookup := func(name string) (string, bool) {
+		return os.LookupEnv(EnvPrefix + name)
+	}
+
+	if v, ok := lookup("DOC_ROOT"); ok {
+		c.DocRoot = v
+	}
+	if v, ok := lookup("LISTEN"); ok {
+		c.Listen = v
+	}
+	durations := map[string]*time.Duration{
+		"READ_TIMEOUT":        &c.Server.ReadTimeout,
+		"READ_HEADER_TIMEOUT": &c.Server.ReadHeaderTimeout,
+		"WRITE_TIMEOUT":       &c.Server.WriteTimeout,
+		"IDLE_TIMEOUT":        &c.Server.IdleTimeout,
+		"SHUTDOWN_TIMEOUT":    &c.Server.ShutdownTimeout,
+
+		"HEALTH_LIBRARY_CHECK_INTERVAL": &c.Health.LibraryCheckInterval,
+	}
+	for name, target := range durations {
+		if v, ok := lookup(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", EnvPrefix, name, err)
+			}
+			*target = d
+		}
+	}
+	if v, ok := lookup("TLS_CERT_FILE"); ok {
+		c.TLS.CertFile = v
+	}
+	if v, ok := lookup("TLS_KEY_FILE"); ok {
+		c.TLS.KeyFile = v
+	}
+	if v, ok := lookup("TLS_REDIRECT_LISTEN"); ok {
+		c.TLS.RedirectListen = v
+	}
+	if v, ok := lookup("AUTH_TYPE"); ok {
+		c.Auth.Type = v
+	}
+	if v, ok := lookup("AUTH_TOKENS"); ok {
+		c.Auth.Tokens = splitList(v)
+	}
+	if v, ok := lookup("AUTH_USERS"); ok {
+		// user1:pass1,user2:pass2
+		c.Auth.Users = make(map[string]string)
+		for _, pair := range splitList(v) {
+			user, pass, found := strings.Cut(pair, ":")
+			if !found {
+				return fmt.Errorf("%sAUTH_USERS: expected user:password, got %q", EnvPrefix, pair)
+			}
+			c.Auth.Users[user] = pass
+		}
+	}
+	if v, ok := lookup("UPLOAD_MAX_SIZE"); ok {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%sUPLOAD_MAX_SIZE: %w", EnvPrefix, err)
+		}
+		c.Upload.MaxSize = size
+	}
+	if v, ok := lookup("LOG_LEVEL"); ok {
+		c.Log.Level = v
+	}
+	if v, ok := lookup("LOG_FORMAT"); ok {
+		c.Log.Format = v
+	}
+	if v, ok := lookup("LOG_FILE"); ok {
+		c.Log.File = v
+	}
+	if v, ok := lookup("HEALTH_MIN_FREE_BYTES"); ok {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%sHEALTH_MIN_FREE_BYTES: %w", EnvPrefix, err)
+		}
+		c.Health.MinFreeBytes = size
+	}
+	if v, ok := lookup("FRONTEND_DIR"); ok {
+		c.Frontend.Dir = v
+	}
+	if v, ok := lookup("FRONTEND_EMBEDDED"); ok {
+		embedded, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%sFRONTEND_EMBEDDED: %w", EnvPrefix, err)
+		}
+		c.Frontend.Embedded = embedded
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.DocRoot == "" {
+		errs = append(errs, errors.New("doc_root must not be empty"))
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("listen: %w", err))
+	}
+
+	// Zero disables a timeout, except for shutdown which needs a deadline
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"read_timeout", c.Server.ReadTimeout},
+		{"read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"write_timeout", c.Server.WriteTimeout},
+		{"idle_timeout", c.Server.IdleTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value < 0 {
+			errs = append(errs, fmt.Errorf("server: %s must not be negative", t.name))
+		}
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server: shutdown_timeout must be positive"))
+	}
+
+	if c.TLS.Enabled() {
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			errs = append(errs, errors.New("tls: cert_file and key_file must be set together"))
+		}
+		for _, file := range []string{c.TLS.CertFile, c.TLS.KeyFile} {
+			if file == "" {
+				continue
+			}
+			if _, err := os.Stat(file); err != nil {
+				errs = append(errs, fmt.Errorf("tls: %w", err))
+			}
+		}
+	}
+	if c.TLS.RedirectListen != "" {
+		if !c.TLS.Enabled() {
+			errs = append(errs, errors.New("tls: redirect_listen requires cert_file and key_file"))
+		}
+		if _, _, err := net.SplitHostPort(c.TLS.RedirectListen); err != nil {
+			errs = append(errs, fmt.Errorf("tls: redirect_listen: %w", err))
+		}
+	}
+
+	switch c.Auth.Type {
+	case "", "none":
+	case "basic":
+		if len(c.Auth.Users) == 0 {
+			errs = append(errs, errors.New("auth: basic auth requires at least one user"))
+		}
+	case "token":
+		if len(c.Auth.Tokens) == 0 {
+			errs = append(errs, errors.New("auth: token auth requires at least one token"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth: unknown type %q, expected none, basic or token", c.Auth.Type))
+	}
+
+	if c.Upload.MaxSize <= 0 {
+		errs = append(errs, errors.New("upload: max_size must be positive"))
+	}
+
+	if c.Health.MinFreeBytes < 0 {
+		errs = append(errs, errors.New("health: min_free_bytes must not be negative"))
+	}
+	if c.Health.LibraryCheckInterval < 0 {
+		errs = append(errs, errors.New("health: library_check_interval must not be negative"))
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log: unknown level %q, expected debug, info, warn or error", c.Log.Level))
+	}
+	switch c.Log.Format {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("log: unknown format %q, expected text or json", c.Log.Format))
+	}
+
+	if c.Frontend.Dir != "" {
+		if info, err := os.Stat(c.Frontend.Dir); err != nil {
+			errs = append(errs, fmt.Errorf("frontend: %w", err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("frontend: %s is not a directory", c.Frontend.Dir))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"main/apierror"
+	"main/service"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// configFormat returns "yaml" or "json" from the format query parameter,
+// falling back to the request content type
+func configFormat(c *gin.Context) string {
+	format := c.Query("format")
+	if format == "" && strings.Contains(c.ContentType(), "yaml") {
+		format = "yaml"
+	}
+	if format == "yml" {
+		format = "yaml"
+	}
+	if format != "yaml" {
+		format = "json"
+	}
+	return format
+}
+
+/*
+	{
+		"changes": [
+			{"name": "blog", "key": "name", "value": "My Library"},
+			{"name": "theme", "key": "color", "delete": true}
+		]
+	}
+*/
+// BatchUpdateLibraryConfig applies many config changes atomically
+func BatchUpdateLibraryConfig(libraries service.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		type BatchRequest struct {
+			Changes []service.ConfigChange `json:"changes"`
+		}
+
+		var req BatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+
+		if len(req.Changes) == 0 {
+			errorResponse(c, apierror.New(apierror.NoChanges))
+			return
+		}
+		if err := libraries.ApplyConfig(c.Request.Context(), libraryName, req.Changes, false); err != nil {
+			serviceError(c, "Failed to update config", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Config updated successfully", "applied": len(req.Changes)})
+	}
+}
+
+// ExportLibraryConfig returns a library's configuration as a name -> key -> value
+// document in JSON (default) or YAML (format=yaml). Read-only keys are left out
+// so the export can be imported into another library as is.
+func ExportLibraryConfig(libraries service.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		config, err := libraries.ExportConfig(c.Request.Context(), libraryName)
+		if err != nil {
+			serviceError(c, "Failed to query config", err)
+			return
+		}
+
+		if configFormat(c) == "yaml" {
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", libraryName+"-config.yaml"))
+			c.YAML(http.StatusOK, config)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", libraryName+"-config.json"))
+		c.JSON(http.StatusOK, config)
+	}
+}
+
+// MaxConfigImportSize caps the body of a config import, configs are small
+const MaxConfigImportSize = 1 << 20
+
+// ImportLibraryConfig applies a document produced by ExportLibraryConfig in a
+// single transaction. With replace=true, keys missing from the document are removed.
+func ImportLibraryConfig(libraries service.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			invalidRequest(c, err)
+			return
+		}
+
+		var config map[string]map[string]string
+		if configFormat(c) == "yaml" {
+			err = yaml.Unmarshal(body, &config)
+		} else {
+			err = json.Unmarshal(body, &config)
+		}
+		if err != nil {
+			errorResponse(c, apierror.Wrap(apierror.InvalidConfigDocument, err).With("reason", err.Error()))
+			return
+		}
+
+		// Apply in a stable order so errors are reproducible
+		var changes []service.ConfigChange
+		for name, keys := range config {
+			for key, value := range keys {
+				changes = append(changes, service.ConfigChange{Name: name, Key: key, Value: value})
+			}
+		}
+		sort.Slice(changes, func(i, j int) bool {
+			if changes[i].Name != changes[j].Name {
+				return changes[i].Name < changes[j].Name
+			}
+			return changes[i].Key < changes[j].Key
+		})
+
+		if err := libraries.ApplyConfig(c.Request.Context(), libraryName, changes, c.Query("replace") == "true"); err != nil {
+			serviceError(c, "Failed to import config", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Config imported successfully", "applied": len(changes)})
+	}
+}
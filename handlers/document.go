@@ -2,128 +2,100 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"main/apierror"
 	"main/models"
+	"main/service"
+	"main/store"
 
 	"github.com/gin-gonic/gin"
-	_ "modernc.org/sqlite"
 )
 
-// getLibraryDB opens a connection to the specified library's database
-func getLibraryDB(docRoot string, libraryName string) (*sql.DB, error) {
-	libPath := filepath.Join(docRoot, libraryName)
-	dbPath := filepath.Join(libPath, "blog.db")
-	return sql.Open("sqlite", dbPath)
+// getLibraryDB returns the shared connection to the specified library's database.
+// The returned release function must be called when the request is done with it.
+// Pass write as true for requests that modify the library.
+func getLibraryDB(docRoot string, libraryName string, write bool) (*sql.DB, func(), error) {
+	return store.Acquire(docRoot, libraryName, write)
 }
 
-func CreateDocument(docRoot string) gin.HandlerFunc {
+// libraryError writes the response for an error returned by getLibraryDB
+func libraryError(c *gin.Context, err error) {
+	serviceError(c, "Failed to connect to library database", service.LibraryError(c.Query("library"), err))
+}
+
+// serviceError writes the response for an error returned by a service: API
+// errors as they are, anything else as a logged internal error. API errors
+// that report a server-side failure, such as an incomplete transfer, are
+// logged with their cause too.
+func serviceError(c *gin.Context, message string, err error, attrs ...any) {
+	var e *apierror.Error
+	if errors.As(err, &e) {
+		if e.Err != nil && e.Status() == http.StatusInternalServerError {
+			logError(c, message, e.Err, attrs...)
+		}
+		errorResponse(c, e)
+		return
+	}
+	internalError(c, message, err, attrs...)
+}
+
+func CreateDocument(documents service.DocumentService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get library name from query parameter
 		libraryName := c.Query("library")
 		if libraryName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Library name is required"})
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
 			return
 		}
-		
-		// Open connection to the library's database
-		db, err := getLibraryDB(docRoot, libraryName)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to library database"})
-			return
-		}
-		defer db.Close()
-		
+
 		var doc models.Document
 		if err := c.ShouldBindJSON(&doc); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			invalidRequest(c, err)
 			return
 		}
-		res, err := db.Exec("INSERT INTO documents (title, content, parent_id) VALUES (?, ?, ?)", doc.Title, doc.Content, doc.ParentID)
+		id, err := documents.Create(c.Request.Context(), libraryName, doc)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			serviceError(c, "Failed to create document", err)
 			return
 		}
-		id, _ := res.LastInsertId()
-		c.JSON(http.StatusOK, gin.H{"id": id})
+		c.JSON(http.StatusOK, gin.H{"id": id, "broken_links": brokenLinks(c, documents, libraryName, id)})
 	}
 }
 
-func GetDocumentTree(docRoot string) gin.HandlerFunc {
+func GetDocumentTree(documents service.DocumentService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get library name from query parameter
 		libraryName := c.Query("library")
 		if libraryName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Library name is required"})
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
 			return
 		}
-		
-		// Open connection to the library's database
-		db, err := getLibraryDB(docRoot, libraryName)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to library database"})
-			return
-		}
-		defer db.Close()
-		
-		// Initialize empty docs array
-		docs := []models.Document{}
-		
-		// Check if the documents table exists
-		var count int
-		row := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type='table' AND name='documents'")
-		if err := row.Scan(&count); err != nil {
-			// For any error, return an error response
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
-			return
-		}
-		
-		// If table doesn't exist, return empty array
-		if count == 0 {
-			c.JSON(http.StatusOK, docs)
-			return
-		}
-		
-		// Table exists, query the documents
-		rows, err := db.Query("SELECT id, title, content, parent_id FROM documents")
+
+		docs, err := documents.Tree(c.Request.Context(), libraryName)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			serviceError(c, "Failed to query documents", err)
 			return
 		}
-		defer rows.Close()
 
-		for rows.Next() {
-			var doc models.Document
-			if err := rows.Scan(&doc.ID, &doc.Title, &doc.Content, &doc.ParentID); err != nil {
-				continue // Skip documents with scan errors
-			}
-			docs = append(docs, doc)
-		}
-		
 		// Always return a JSON array, even if empty
 		c.JSON(http.StatusOK, docs) // 可以递归构造树结构
 	}
 }
 
-func UpdateDocumentParent(docRoot string) gin.HandlerFunc {
+func UpdateDocumentParent(documents service.DocumentService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get library name from query parameter
 		libraryName := c.Query("library")
 		if libraryName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Library name is required"})
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
 			return
 		}
-		
-		// Open connection to the library's database
-		db, err := getLibraryDB(docRoot, libraryName)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to library database"})
-			return
-		}
-		defer db.Close()
-		
+
 		type UpdateRequest struct {
 			ID       int64 `json:"id"`
 			ParentID int64 `json:"parent_id"`
@@ -131,19 +103,12 @@ func UpdateDocumentParent(docRoot string) gin.HandlerFunc {
 
 		var req UpdateRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			invalidRequest(c, err)
 			return
 		}
 
-		// 防止将节点拖动到自己或其子节点下（避免递归死循环结构）
-		if req.ID == req.ParentID {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "不能将节点移动到自身下"})
-			return
-		}
-
-		_, err = db.Exec("UPDATE documents SET parent_id = ? WHERE id = ?", req.ParentID, req.ID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := documents.Move(c.Request.Context(), libraryName, req.ID, req.ParentID); err != nil {
+			serviceError(c, "Failed to update parent", err, "document_id", req.ID, "parent_id", req.ParentID)
 			return
 		}
 
@@ -152,147 +117,170 @@ func UpdateDocumentParent(docRoot string) gin.HandlerFunc {
 }
 
 // GetDocumentByID retrieves a document by its ID
-func GetDocumentByID(docRoot string) gin.HandlerFunc {
+func GetDocumentByID(documents service.DocumentService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get library name from query parameter
 		libraryName := c.Query("library")
 		if libraryName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Library name is required"})
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
 			return
 		}
-		
+
 		// Get document ID from query parameter
 		docID := c.Query("id")
 		if docID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Document ID is required"})
+			errorResponse(c, apierror.New(apierror.DocumentIDRequired))
 			return
 		}
-		
-		// Open connection to the library's database
-		db, err := getLibraryDB(docRoot, libraryName)
+		// IDs that are not numbers cannot match any document
+		id, err := strconv.ParseInt(docID, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to library database"})
+			errorResponse(c, apierror.New(apierror.DocumentNotFound))
 			return
 		}
-		defer db.Close()
-		
-		// Check if the documents table exists
-		var count int
-		row := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type='table' AND name='documents'")
-		if err := row.Scan(&count); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
-			return
-		}
-		
-		// If table doesn't exist, return not found
-		if count == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
-			return
-		}
-		
-		// Query for the document
-		var doc models.Document
-		row = db.QueryRow("SELECT id, title, content, parent_id FROM documents WHERE id = ?", docID)
-		if err := row.Scan(&doc.ID, &doc.Title, &doc.Content, &doc.ParentID); err != nil {
-			if err == sql.ErrNoRows {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + err.Error()})
-			}
+
+		doc, err := documents.Get(c.Request.Context(), libraryName, id)
+		if err != nil {
+			serviceError(c, "Database error", err, "document_id", docID)
 			return
 		}
-		
+
 		// Return the document
 		c.JSON(http.StatusOK, doc)
 	}
 }
 
 // UpdateDocument updates a document's title and/or content
-func UpdateDocument(docRoot string) gin.HandlerFunc {
+func UpdateDocument(documents service.DocumentService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get library name from query parameter
 		libraryName := c.Query("library")
 		if libraryName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Library name is required"})
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
 			return
 		}
-		
-		// Open connection to the library's database
-		db, err := getLibraryDB(docRoot, libraryName)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to library database"})
-			return
-		}
-		defer db.Close()
-		
+
 		// Define request structure with pointer for Content to detect if it was provided
 		type UpdateRequest struct {
 			ID      int64   `json:"id"`
 			Title   string  `json:"title"`   // Optional
 			Content *string `json:"content"` // Pointer allows us to detect if field was provided
 		}
-		
+
 		var req UpdateRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			invalidRequest(c, err)
 			return
 		}
-		
-		// Validate request
-		if req.ID <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Document ID is required"})
+
+		// An empty title leaves the title unchanged, an empty content clears it
+		update := service.DocumentUpdate{Content: req.Content}
+		if req.Title != "" {
+			update.Title = &req.Title
+		}
+
+		updated, err := documents.Update(c.Request.Context(), libraryName, req.ID, update)
+		if err != nil {
+			serviceError(c, "Failed to update document", err, "document_id", req.ID)
 			return
 		}
-		
-		// Check if document exists
-		var exists bool
-		row := db.QueryRow("SELECT EXISTS(SELECT 1 FROM documents WHERE id = ?)", req.ID)
-		if err := row.Scan(&exists); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check document existence"})
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Document updated successfully",
+			"updated":      updated,
+			"broken_links": brokenLinks(c, documents, libraryName, req.ID),
+		})
+	}
+}
+
+// ListDocuments returns a page of documents, optionally filtered and with
+// only some fields
+func ListDocuments(documents service.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
 			return
 		}
-		
-		if !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+
+		opts := service.DocumentListOptions{
+			Sort:        c.Query("sort"),
+			Cursor:      c.Query("cursor"),
+			TitlePrefix: c.Query("title_prefix"),
+			Tags:        c.QueryArray("tag"),
+		}
+		switch c.Query("order") {
+		case "", "asc":
+		case "desc":
+			opts.Desc = true
+		default:
+			listParamError(c, "order must be asc or desc")
 			return
 		}
-		
-		// Build update query based on provided fields
-		var updateFields []string
-		var args []interface{}
-		
-		if req.Title != "" {
-			updateFields = append(updateFields, "title = ?")
-			args = append(args, req.Title)
+		if v := c.Query("limit"); v != "" {
+			// Zero is rejected rather than meaning the default
+			limit, err := strconv.Atoi(v)
+			if err != nil || limit < 1 {
+				listParamError(c, "limit must be between 1 and 500")
+				return
+			}
+			opts.Limit = limit
 		}
-		
-		// Check if content field was provided in the request (even if empty)
-		// Using a pointer allows us to detect if the field was included in the JSON
-		if req.Content != nil {
-			updateFields = append(updateFields, "content = ?")
-			args = append(args, *req.Content) // Dereference the pointer to get the value
+		if v := c.Query("parent_id"); v != "" {
+			parentID, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				listParamError(c, "parent_id must be a number")
+				return
+			}
+			opts.ParentID = &parentID
+		}
+		for _, bound := range []struct {
+			param string
+			t     *time.Time
+		}{{"updated_after", &opts.UpdatedAfter}, {"updated_before", &opts.UpdatedBefore}} {
+			v := c.Query(bound.param)
+			if v == "" {
+				continue
+			}
+			t, err := parseListTime(v)
+			if err != nil {
+				listParamError(c, bound.param+" must be an RFC 3339 time or a YYYY-MM-DD date")
+				return
+			}
+			*bound.t = t
+		}
+		// meta.<key>=<value>, repeated for any of several values
+		for param, values := range c.Request.URL.Query() {
+			if key, ok := strings.CutPrefix(param, "meta."); ok {
+				if opts.Meta == nil {
+					opts.Meta = make(map[string][]string)
+				}
+				opts.Meta[key] = values
+			}
 		}
-		
-		// If no fields to update, return early
-		if len(updateFields) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
-			return
+		if v := c.Query("fields"); v != "" {
+			for _, field := range strings.Split(v, ",") {
+				opts.Fields = append(opts.Fields, strings.TrimSpace(field))
+			}
 		}
-		
-		// Build and execute the query
-		query := "UPDATE documents SET " + strings.Join(updateFields, ", ") + " WHERE id = ?"
-		args = append(args, req.ID)
-		
-		result, err := db.Exec(query, args...)
+
+		page, err := documents.List(c.Request.Context(), libraryName, opts)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update document"})
+			serviceError(c, "Failed to list documents", err)
 			return
 		}
-		
-		rowsAffected, _ := result.RowsAffected()
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Document updated successfully",
-			"updated": rowsAffected > 0,
-		})
+		c.JSON(http.StatusOK, page)
 	}
 }
+
+// parseListTime accepts a full timestamp or a date, which is midnight UTC
+func parseListTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
+
+func listParamError(c *gin.Context, reason string) {
+	errorResponse(c, apierror.New(apierror.InvalidRequest).With("reason", reason))
+}
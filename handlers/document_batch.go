@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"main/apierror"
+	"main/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	{
+		"operations": [
+			{"op": "create", "temp_id": "section", "title": "Section", "parent_id": 0},
+			{"op": "create", "title": "Page", "parent_temp_id": "section"},
+			{"op": "move", "id": 12, "parent_temp_id": "section"},
+			{"op": "update", "id": 12, "title": "Renamed"},
+			{"op": "delete", "id": 7}
+		]
+	}
+*/
+// BatchDocuments applies many document operations atomically, in order.
+// Deleting a document also deletes its descendants.
+func BatchDocuments(documents service.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		type BatchRequest struct {
+			Operations []service.DocumentOperation `json:"operations"`
+		}
+
+		var req BatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+
+		if len(req.Operations) == 0 {
+			errorResponse(c, apierror.New(apierror.NoChanges))
+			return
+		}
+		result, err := documents.Batch(c.Request.Context(), libraryName, req.Operations)
+		if err != nil {
+			serviceError(c, "Failed to apply document operations", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Documents updated successfully",
+			"applied":      len(req.Operations),
+			"ids":          result.IDs,
+			"deleted":      result.Deleted,
+			"broken_links": result.BrokenLinks,
+		})
+	}
+}
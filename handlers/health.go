@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"main/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Healthz reports that the process is alive and serving requests
+func Healthz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// Readyz runs the readiness checks and answers 503 with the failed checks
+// when anything is wrong
+func Readyz(health service.HealthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checks := health.Ready(c.Request.Context())
+
+		failures := []service.HealthCheck{}
+		for _, check := range checks {
+			if !check.OK {
+				failures = append(failures, check)
+			}
+		}
+
+		status, text := http.StatusOK, "ok"
+		if len(failures) > 0 {
+			status, text = http.StatusServiceUnavailable, "unavailable"
+		}
+		c.JSON(status, gin.H{"status": text, "checks": checks, "failures": failures})
+	}
+}
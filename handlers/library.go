@@ -1,241 +1,87 @@
 package handlers
 
 import (
-	"database/sql"
 	"net/http"
-	"os"
-	"path/filepath"
+
+	"main/apierror"
+	"main/service"
 
 	"github.com/gin-gonic/gin"
-	_ "modernc.org/sqlite"
 )
 
 /*
 	{
 		"name": "mybook1",
-		"base_path": "./storage"
+		"base_path": "./storage",
+		"template": "template_dir"
 	  }
 */
-func CreateLibrary() gin.HandlerFunc {
+func CreateLibrary(libraries service.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		type Req struct {
 			Name     string `json:"name"`
 			BasePath string `json:"base_path"` // 库路径
+			Template string `json:"template"`  // 模板知识库目录，可选
 		}
 		var req Req
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		// Use the base_path directly as the library path
-		libPath := req.BasePath
-		if libPath == "" {
-			libPath = req.Name // Default path if not provided
-		}
-		blogDbPath := filepath.Join(libPath, "blog.db")
-		picPath := filepath.Join(libPath, "pic")
-
-		// Check if either database file exists
-		dbExists := false
-
-		// Check for blog.db
-		if _, err := os.Stat(blogDbPath); err == nil {
-			dbExists = true
-		}
-
-		if dbExists {
-			c.JSON(http.StatusConflict, gin.H{"error": "知识库已存在"})
+			invalidRequest(c, err)
 			return
 		}
 
-		// If directory exists but no database, that's fine - we'll create the database
-
-		// 创建目录
-		if err := os.MkdirAll(picPath, 0755); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "目录创建失败", "details": err.Error(), "path": picPath})
-			return
-		}
-
-		// 创建 SQLite 数据库并初始化表结构
-		db, err := sql.Open("sqlite", blogDbPath)
+		library, err := libraries.Create(c.Request.Context(), req.Name, req.BasePath, req.Template)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库创建失败", "details": err.Error(), "path": blogDbPath})
+			serviceError(c, "知识库创建失败", err, "path", library.Path)
 			return
 		}
-		defer db.Close()
 
-		// Create documents table
-		_, err = db.Exec(`
-			CREATE TABLE IF NOT EXISTS documents (
-				id INTEGER PRIMARY KEY AUTOINCREMENT,
-				title TEXT,
-				content TEXT,
-				parent_id INTEGER
-			)
-		`)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "数据库表初始化失败", "details": err.Error(), "table": "documents", "path": blogDbPath})
-			return
+		res := gin.H{"message": "知识库创建成功", "name": library.Name, "path": library.Path}
+		if library.Template != "" {
+			res["template"] = library.Template
 		}
-
-		// Create config table
-		_, err = db.Exec(`
-			CREATE TABLE IF NOT EXISTS config (
-				id INTEGER PRIMARY KEY AUTOINCREMENT,
-				name TEXT,
-				key TEXT,
-				value TEXT
-			)
-		`)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "配置表初始化失败", "details": err.Error(), "table": "config", "path": blogDbPath})
-			return
-		}
-
-		// Insert blog name into config table
-		_, err = db.Exec("INSERT INTO config (name, key, value) VALUES (?, ?, ?)", "blog", "name", req.Name)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "配置初始化失败", "details": err.Error(), "operation": "insert config", "path": blogDbPath})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{"message": "知识库创建成功", "name": req.Name, "path": libPath})
+		c.JSON(http.StatusOK, res)
 	}
 }
 
-// ListLibraries returns a list of all library folders in the base path
-func ListLibraries(basePath string) gin.HandlerFunc {
+// ListLibraries returns a list of all library folders in the base path.
+// Archived libraries are hidden unless the archived=true query parameter is set.
+func ListLibraries(libraries service.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Check if base path exists, create it if it doesn't
-		if _, err := os.Stat(basePath); os.IsNotExist(err) {
-			// Create the directory
-			if err := os.MkdirAll(basePath, 0755); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create base path"})
-				return
-			}
-			// Return empty list since the directory was just created
-			c.JSON(http.StatusOK, gin.H{"libraries": []map[string]string{}})
-			return
-		}
-
-		// Read only top-level directories in the base path (non-recursive)
-		entries, err := os.ReadDir(basePath)
+		list, err := libraries.List(c.Request.Context(), c.Query("archived") == "true")
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read directories"})
+			serviceError(c, "Failed to read directories", err)
 			return
 		}
-
-		// Filter top-level directories that contain a blog.db file (which indicates a library)
-		libraries := []map[string]string{}
-		// Non-recursive enumeration - only checking direct children of basePath
-		for _, entry := range entries {
-			if entry.IsDir() {
-				libPath := filepath.Join(basePath, entry.Name())
-				blogDbPath := filepath.Join(libPath, "blog.db")
-
-				dbExists := false
-				dbPath := ""
-
-				// First check for blog.db
-				if _, err := os.Stat(blogDbPath); err == nil {
-					dbExists = true
-					dbPath = blogDbPath
-				}
-
-				// If either database file exists
-				if dbExists {
-					// Open the database to get the blog name from config
-					db, err := sql.Open("sqlite", dbPath)
-					if err == nil {
-						defer db.Close()
-
-						// Try to get blog name from config
-						var blogName string
-						row := db.QueryRow("SELECT value FROM config WHERE name = 'blog' AND key = 'name' LIMIT 1")
-						row.Scan(&blogName)
-
-						// If no blog name found, use directory name
-						if blogName == "" {
-							blogName = entry.Name()
-						}
-
-						libraries = append(libraries, map[string]string{
-							"name": blogName,
-							"path": libPath,
-							"dir":  entry.Name(),
-						})
-					} else {
-						// Fallback if can't open database
-						libraries = append(libraries, map[string]string{
-							"name": entry.Name(),
-							"path": libPath,
-							"dir":  entry.Name(),
-						})
-					}
-				}
-			}
-		}
-
-		c.JSON(http.StatusOK, gin.H{"libraries": libraries})
+		c.JSON(http.StatusOK, gin.H{"libraries": list})
 	}
 }
 
 // GetLibraryConfig retrieves configuration for a specific library
-func GetLibraryConfig(docRoot string) gin.HandlerFunc {
+func GetLibraryConfig(libraries service.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get library name from query parameter
 		libraryName := c.Query("library")
 		if libraryName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Library name is required"})
-			return
-		}
-
-		// Open connection to the library's database
-		db, err := getLibraryDB(docRoot, libraryName)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to library database"})
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
 			return
 		}
-		defer db.Close()
 
-		// Query all config entries
-		rows, err := db.Query("SELECT id, name, key, value FROM config")
+		config, err := libraries.Config(c.Request.Context(), libraryName)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query config"})
+			serviceError(c, "Failed to query config", err)
 			return
 		}
-		defer rows.Close()
-
-		// Build config map
-		config := make(map[string]map[string]string)
-		for rows.Next() {
-			var id int64
-			var name, key, value string
-			if err := rows.Scan(&id, &name, &key, &value); err != nil {
-				continue
-			}
-
-			// Initialize the map for this name if it doesn't exist
-			if _, ok := config[name]; !ok {
-				config[name] = make(map[string]string)
-			}
-
-			// Add the key-value pair
-			config[name][key] = value
-		}
-
 		c.JSON(http.StatusOK, gin.H{"config": config})
 	}
 }
 
 // UpdateLibraryConfig updates configuration for a specific library
-func UpdateLibraryConfig(docRoot string) gin.HandlerFunc {
+func UpdateLibraryConfig(libraries service.LibraryService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get library name from query parameter
 		libraryName := c.Query("library")
 		if libraryName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Library name is required"})
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
 			return
 		}
 
@@ -248,50 +94,61 @@ func UpdateLibraryConfig(docRoot string) gin.HandlerFunc {
 
 		var req ConfigRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			invalidRequest(c, err)
 			return
 		}
 
-		// Validate request
-		if req.Name == "" || req.Key == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Name and key are required"})
+		updated, err := libraries.SetConfig(c.Request.Context(), libraryName, req.Name, req.Key, req.Value)
+		if err != nil {
+			serviceError(c, "Failed to update config", err)
 			return
 		}
 
-		// Open connection to the library's database
-		db, err := getLibraryDB(docRoot, libraryName)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to library database"})
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Config updated successfully",
+			"updated": updated,
+		})
+	}
+}
+
+// DeleteLibraryConfig removes a config key from a library. Known keys fall
+// back to their schema default afterwards.
+func DeleteLibraryConfig(libraries service.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get library name from query parameter
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
 			return
 		}
-		defer db.Close()
 
-		// Check if config exists
-		var count int
-		row := db.QueryRow("SELECT COUNT(*) FROM config WHERE name = ? AND key = ?", req.Name, req.Key)
-		if err := row.Scan(&count); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query config"})
-			return
+		type DeleteRequest struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
 		}
 
-		var result sql.Result
-		if count > 0 {
-			// Update existing config
-			result, err = db.Exec("UPDATE config SET value = ? WHERE name = ? AND key = ?", req.Value, req.Name, req.Key)
-		} else {
-			// Insert new config
-			result, err = db.Exec("INSERT INTO config (name, key, value) VALUES (?, ?, ?)", req.Name, req.Key, req.Value)
+		var req DeleteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			invalidRequest(c, err)
+			return
 		}
 
+		deleted, err := libraries.DeleteConfig(c.Request.Context(), libraryName, req.Name, req.Key)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update config"})
+			serviceError(c, "Failed to delete config", err)
 			return
 		}
 
-		rowsAffected, _ := result.RowsAffected()
 		c.JSON(http.StatusOK, gin.H{
-			"message": "Config updated successfully",
-			"updated": rowsAffected > 0,
+			"message": "Config deleted successfully",
+			"deleted": deleted,
 		})
 	}
 }
+
+// GetConfigSchema returns the registered library config fields
+func GetConfigSchema() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"fields": service.ConfigSchema()})
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"main/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	{
+		"source": "existing_dir",
+		"dir": "new_dir",
+		"name": "New Library"
+	}
+*/
+// CloneLibrary copies an existing library's documents, config and images into a new library
+func CloneLibrary(libraries service.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		type CloneRequest struct {
+			Source string `json:"source"` // Directory of the library to copy
+			Dir    string `json:"dir"`    // Directory of the new library
+			Name   string `json:"name"`   // Display name, defaults to dir
+		}
+
+		var req CloneRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+
+		library, err := libraries.Clone(c.Request.Context(), req.Source, req.Dir, req.Name)
+		if err != nil {
+			serviceError(c, "Failed to clone library", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Library cloned successfully", "name": library.Name, "dir": library.Dir, "path": library.Path})
+	}
+}
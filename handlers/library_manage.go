@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"main/apierror"
+	"main/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deleteTokenTTL is how long a library deletion confirmation token stays valid
+const deleteTokenTTL = 5 * time.Minute
+
+type deleteToken struct {
+	library string
+	expires time.Time
+}
+
+// deleteTokens holds the pending library deletion confirmations
+var deleteTokens = struct {
+	sync.Mutex
+	m map[string]deleteToken
+}{m: make(map[string]deleteToken)}
+
+/*
+	{
+		"dir": "new_dir",
+		"name": "New display name"
+	}
+*/
+// RenameLibrary renames a library directory and/or its display name (blog.name config)
+func RenameLibrary(libraries service.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		type RenameRequest struct {
+			Dir  string `json:"dir"`  // New directory name, optional
+			Name string `json:"name"` // New display name, optional
+		}
+
+		var req RenameRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+
+		library, err := libraries.Rename(c.Request.Context(), libraryName, req.Dir, req.Name)
+		if err != nil {
+			serviceError(c, "Failed to rename library", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Library renamed successfully",
+			"name":    library.Name,
+			"dir":     library.Dir,
+			"path":    library.Path,
+		})
+	}
+}
+
+/*
+	{
+		"archived": true
+	}
+*/
+// ArchiveLibrary marks a library as archived (read-only and hidden from the
+// default list) or restores it
+func ArchiveLibrary(libraries service.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		type ArchiveRequest struct {
+			Archived *bool `json:"archived"` // Defaults to true when omitted
+		}
+
+		var req ArchiveRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+		archived := req.Archived == nil || *req.Archived
+
+		if err := libraries.Archive(c.Request.Context(), libraryName, archived); err != nil {
+			serviceError(c, "Failed to update library", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Library updated successfully", "archived": archived})
+	}
+}
+
+/*
+	{
+		"token": "confirmation token from the first call"
+	}
+*/
+// DeleteLibrary deletes a library and all of its files. The first call without
+// a token returns a confirmation token; repeating the call with that token
+// within deleteTokenTTL performs the deletion.
+func DeleteLibrary(libraries service.LibraryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		type DeleteRequest struct {
+			Token string `json:"token"`
+		}
+
+		var req DeleteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+
+		if !libraries.Exists(libraryName) {
+			errorResponse(c, apierror.New(apierror.LibraryNotFound).With("library", libraryName))
+			return
+		}
+
+		now := time.Now()
+		deleteTokens.Lock()
+		for token, pending := range deleteTokens.m {
+			if now.After(pending.expires) {
+				delete(deleteTokens.m, token)
+			}
+		}
+
+		// First step: hand out a confirmation token
+		if req.Token == "" {
+			buf := make([]byte, 16)
+			if _, err := rand.Read(buf); err != nil {
+				deleteTokens.Unlock()
+				internalError(c, "Failed to generate confirmation token", err)
+				return
+			}
+			token := hex.EncodeToString(buf)
+			expires := now.Add(deleteTokenTTL)
+			deleteTokens.m[token] = deleteToken{library: libraryName, expires: expires}
+			deleteTokens.Unlock()
+
+			c.JSON(http.StatusAccepted, gin.H{
+				"message":    "Repeat the request with this token to confirm deletion",
+				"token":      token,
+				"expires_at": expires,
+			})
+			return
+		}
+
+		// Second step: the token must match this library and is single use
+		pending, ok := deleteTokens.m[req.Token]
+		if ok {
+			delete(deleteTokens.m, req.Token)
+		}
+		deleteTokens.Unlock()
+		if !ok || pending.library != libraryName {
+			errorResponse(c, apierror.New(apierror.InvalidConfirmation))
+			return
+		}
+
+		if err := libraries.Delete(c.Request.Context(), libraryName); err != nil {
+			serviceError(c, "Failed to delete library", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Library deleted successfully"})
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"main/metrics"
+	"main/service"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// libraryStatsTTL is how long library sizes are cached between scrapes,
+// since walking every pic directory is comparatively expensive
+const libraryStatsTTL = 30 * time.Second
+
+var (
+	libraryDocumentsDesc = prometheus.NewDesc("doc_admin_library_documents",
+		"Number of documents per library.", []string{"library"}, nil)
+	libraryPicBytesDesc = prometheus.NewDesc("doc_admin_library_pic_bytes",
+		"Total size of the pic directory per library in bytes.", []string{"library"}, nil)
+)
+
+// libraryCollector reports document counts and image storage per library
+type libraryCollector struct {
+	mu        sync.Mutex
+	libraries service.LibraryService
+	stats     map[string]service.LibraryStats
+	collected time.Time
+}
+
+// libraryMetrics is registered once; the router points it at its libraries
+var libraryMetrics = &libraryCollector{}
+
+func init() {
+	metrics.Registry.MustRegister(libraryMetrics)
+}
+
+// SetMetricsLibraries selects the libraries reported by the per-library gauges
+func SetMetricsLibraries(libraries service.LibraryService) {
+	libraryMetrics.mu.Lock()
+	defer libraryMetrics.mu.Unlock()
+	libraryMetrics.libraries = libraries
+	libraryMetrics.stats = nil
+}
+
+func (lc *libraryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- libraryDocumentsDesc
+	ch <- libraryPicBytesDesc
+}
+
+func (lc *libraryCollector) Collect(ch chan<- prometheus.Metric) {
+	lc.mu.Lock()
+	if lc.libraries == nil {
+		lc.mu.Unlock()
+		return
+	}
+	if lc.stats == nil || time.Since(lc.collected) >= libraryStatsTTL {
+		lc.stats = gatherLibraryStats(lc.libraries)
+		lc.collected = time.Now()
+	}
+	stats := lc.stats
+	lc.mu.Unlock()
+
+	for library, s := range stats {
+		ch <- prometheus.MustNewConstMetric(libraryDocumentsDesc, prometheus.GaugeValue, float64(s.Documents), library)
+		ch <- prometheus.MustNewConstMetric(libraryPicBytesDesc, prometheus.GaugeValue, float64(s.PicBytes), library)
+	}
+}
+
+// gatherLibraryStats reads the current stats of every library. Libraries that
+// cannot be read are skipped rather than failing the whole scrape.
+func gatherLibraryStats(libraries service.LibraryService) map[string]service.LibraryStats {
+	stats := make(map[string]service.LibraryStats)
+	dirs, err := libraries.Dirs()
+	if err != nil {
+		return stats
+	}
+
+	for _, name := range dirs {
+		s, err := libraries.Stats(context.Background(), name)
+		if err != nil {
+			continue
+		}
+		stats[name] = s
+	}
+	return stats
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"main/apierror"
+	"main/models"
+	"main/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// brokenLinks returns the links of freshly written documents that point to
+// missing documents. The write already succeeded, so a failing lookup is only
+// logged.
+func brokenLinks(c *gin.Context, documents service.DocumentService, library string, ids ...int64) []models.Link {
+	broken, err := documents.BrokenLinks(c.Request.Context(), library, ids...)
+	if err != nil {
+		logError(c, "Failed to check links", err, "document_ids", ids)
+		return []models.Link{}
+	}
+	return broken
+}
+
+// GetBacklinks lists the documents that link to a document
+func GetBacklinks(documents service.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		docID := c.Query("id")
+		if docID == "" {
+			errorResponse(c, apierror.New(apierror.DocumentIDRequired))
+			return
+		}
+		// IDs that are not numbers cannot match any document
+		id, err := strconv.ParseInt(docID, 10, 64)
+		if err != nil {
+			errorResponse(c, apierror.New(apierror.DocumentNotFound))
+			return
+		}
+
+		backlinks, err := documents.Backlinks(c.Request.Context(), libraryName, id)
+		if err != nil {
+			serviceError(c, "Failed to query backlinks", err, "document_id", id)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"backlinks": backlinks})
+	}
+}
+
+// GetLibraryGraph returns the documents of a library as nodes and the links
+// between them as edges, links to missing documents are marked broken
+func GetLibraryGraph(documents service.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		graph, err := documents.Graph(c.Request.Context(), libraryName)
+		if err != nil {
+			serviceError(c, "Failed to query links", err)
+			return
+		}
+		c.JSON(http.StatusOK, graph)
+	}
+}
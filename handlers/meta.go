@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"main/apierror"
+	"main/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMetaSchema lists the metadata fields of a library, defined by its meta.*
+// config entries
+func GetMetaSchema(documents service.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		fields, err := documents.MetaSchema(c.Request.Context(), libraryName)
+		if err != nil {
+			serviceError(c, "Failed to read metadata schema", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"fields": fields})
+	}
+}
+
+/*
+	{"id": 1, "meta": {"owner": "ann", "status": "review", "review_date": null}}
+*/
+// UpdateDocumentMeta sets metadata values of a document, null removes a key
+func UpdateDocumentMeta(documents service.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		type MetaRequest struct {
+			ID   int64              `json:"id"`
+			Meta map[string]*string `json:"meta"`
+		}
+
+		var req MetaRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+
+		meta, err := documents.SetMeta(c.Request.Context(), libraryName, req.ID, req.Meta)
+		if err != nil {
+			serviceError(c, "Failed to update metadata", err, "document_id", req.ID)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Metadata updated successfully", "meta": meta})
+	}
+}
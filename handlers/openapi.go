@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+
+	"main/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPISpec serves the OpenAPI description of the API
+func OpenAPISpec() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", api.Spec)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"main/apierror"
+	"main/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorResponse writes the error envelope together with the request ID, so a
+// user reporting a problem can be matched with the server log
+func errorResponse(c *gin.Context, e *apierror.Error) {
+	apierror.Abort(c, middleware.GetRequestID(c), e)
+}
+
+// invalidRequest answers a request whose body or parameters could not be parsed
+func invalidRequest(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		errorResponse(c, apierror.Wrap(apierror.PayloadTooLarge, err).With("limit", tooLarge.Limit))
+		return
+	}
+	errorResponse(c, apierror.Wrap(apierror.InvalidRequest, err).With("reason", err.Error()))
+}
+
+// internalError logs the underlying error with the request context and
+// sends the client only a generic error. Extra attributes such as the
+// document ID can be passed as slog key/value pairs.
+func internalError(c *gin.Context, message string, err error, attrs ...any) {
+	logError(c, message, err, attrs...)
+	errorResponse(c, apierror.Wrap(apierror.Internal, err))
+}
+
+// logError logs err with the request context and records it on the gin context
+func logError(c *gin.Context, message string, err error, attrs ...any) {
+	args := []any{
+		"request_id", middleware.GetRequestID(c),
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+	}
+	if library := c.Query("library"); library != "" {
+		args = append(args, "library", library)
+	}
+	args = append(args, attrs...)
+	args = append(args, "error", err)
+	slog.ErrorContext(c.Request.Context(), message, args...)
+
+	c.Error(err)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"main/apierror"
+	"main/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLibraryTags lists the tags of a library with their document counts
+func GetLibraryTags(documents service.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		tags, err := documents.Tags(c.Request.Context(), libraryName)
+		if err != nil {
+			serviceError(c, "Failed to query tags", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"tags": tags})
+	}
+}
+
+/*
+	{"id": 1, "tags": ["draft", "go"]}
+*/
+// AddDocumentTags tags a document, creating tags as needed
+func AddDocumentTags(documents service.DocumentService) gin.HandlerFunc {
+	return changeDocumentTags(documents.AddTags)
+}
+
+// RemoveDocumentTags takes tags off a document. Tags it does not have are ignored.
+func RemoveDocumentTags(documents service.DocumentService) gin.HandlerFunc {
+	return changeDocumentTags(documents.RemoveTags)
+}
+
+func changeDocumentTags(change func(ctx context.Context, library string, id int64, tags []string) ([]string, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		type TagsRequest struct {
+			ID   int64    `json:"id"`
+			Tags []string `json:"tags"`
+		}
+
+		var req TagsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+
+		tags, err := change(c.Request.Context(), libraryName, req.ID, req.Tags)
+		if err != nil {
+			serviceError(c, "Failed to update tags", err, "document_id", req.ID)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Tags updated successfully", "tags": tags})
+	}
+}
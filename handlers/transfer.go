@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"main/apierror"
+	"main/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	{
+		"source_library": "lib_a",
+		"target_library": "lib_b",
+		"id": 12,
+		"parent_id": 0,
+		"mode": "copy"
+	}
+*/
+// TransferDocument copies or moves a document subtree from one library to another.
+// Documents receive new IDs in the target library, their pic/<docid> folders are
+// relocated to the new IDs and image and document links inside the content are
+// rewritten. Moving also points links to the moved documents at the target
+// library, so they do not break.
+func TransferDocument(documents service.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		type TransferRequest struct {
+			SourceLibrary string `json:"source_library"`
+			TargetLibrary string `json:"target_library"`
+			ID            int64  `json:"id"`        // Root of the subtree to transfer
+			ParentID      int64  `json:"parent_id"` // New parent in the target library, 0 for root
+			Mode          string `json:"mode"`      // "copy" (default) or "move"
+		}
+
+		var req TransferRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+
+		if req.Mode == "" {
+			req.Mode = "copy"
+		}
+		if req.Mode != "copy" && req.Mode != "move" {
+			errorResponse(c, apierror.New(apierror.InvalidRequest).With("reason", "mode must be copy or move"))
+			return
+		}
+
+		idMap, err := documents.Transfer(c.Request.Context(), req.SourceLibrary, req.TargetLibrary, req.ID, req.ParentID, req.Mode == "move")
+		if err != nil {
+			serviceError(c, "Failed to transfer documents", err,
+				"source_library", req.SourceLibrary, "target_library", req.TargetLibrary, "document_id", req.ID)
+			return
+		}
+
+		// JSON object keys must be strings
+		idStrings := make(map[string]int64, len(idMap))
+		for oldID, newID := range idMap {
+			idStrings[fmt.Sprint(oldID)] = newID
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Documents transferred successfully",
+			"id":      idMap[req.ID],
+			"ids":     idStrings,
+		})
+	}
+}
@@ -5,9 +5,14 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"main/apierror"
+	"main/metrics"
+	"main/store"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -17,19 +22,44 @@ func generateUniqueFilename(extension string) string {
 	return fmt.Sprintf("%d%s", timestamp, extension)
 }
 
+// validImageDir reports whether docID can name a pic/<docid> folder, so
+// values such as ".." cannot reach files outside of it
+func validImageDir(docID string) bool {
+	id, err := strconv.ParseInt(docID, 10, 64)
+	return err == nil && id > 0 && strconv.FormatInt(id, 10) == docID
+}
+
+// validImageName reports whether filename is a plain file name
+func validImageName(filename string) bool {
+	return filename != "" && filename != "." && filename != ".." && !strings.ContainsAny(filename, `/\`)
+}
+
 func UploadImage(docRoot string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get library name from query parameter
 		libraryName := c.Query("library")
 		if libraryName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Library name is required"})
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
 			return
 		}
 		
+		// Hold the library for the duration of the upload so it cannot be
+		// renamed or deleted underneath us, and reject archived libraries
+		_, release, err := getLibraryDB(docRoot, libraryName, true)
+		if err != nil {
+			libraryError(c, err)
+			return
+		}
+		defer release()
+
 		docID := c.Param("id")
+		if !validImageDir(docID) {
+			errorResponse(c, apierror.New(apierror.InvalidRequest).With("reason", "document ID must be a positive number"))
+			return
+		}
 		file, err := c.FormFile("file")
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No file is received"})
+			errorResponse(c, apierror.New(apierror.FileRequired))
 			return
 		}
 
@@ -37,15 +67,15 @@ func UploadImage(docRoot string) gin.HandlerFunc {
 		libraryPath := filepath.Join(docRoot, libraryName)
 		targetDir := filepath.Join(libraryPath, "pic", docID)
 		if err := os.MkdirAll(targetDir, 0755); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot create directory"})
+			internalError(c, "Cannot create directory", err, "document_id", docID)
 			return
 		}
 
-		// Determine filename
-		filename := file.Filename
+		// Determine filename, removing any path components that might be in it
+		filename := filepath.Base(file.Filename)
 		
-		// If no filename or it's empty, generate a unique one
-		if filename == "" {
+		// If no usable filename, generate a unique one
+		if !validImageName(filename) {
 			// Try to determine extension from content type
 			extension := ".png" // Default extension
 			contentType := file.Header.Get("Content-Type")
@@ -59,10 +89,6 @@ func UploadImage(docRoot string) gin.HandlerFunc {
 			
 			filename = generateUniqueFilename(extension)
 		} else {
-			// Ensure filename is safe and unique
-			// Remove any path components that might be in the filename
-			filename = filepath.Base(filename)
-			
 			// Check if file already exists, if so, make it unique
 			fileExt := filepath.Ext(filename)
 			fileBase := strings.TrimSuffix(filename, fileExt)
@@ -80,10 +106,12 @@ func UploadImage(docRoot string) gin.HandlerFunc {
 
 		dst := filepath.Join(targetDir, filename)
 		if err := c.SaveUploadedFile(file, dst); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot save file"})
+			internalError(c, "Cannot save file", err, "document_id", docID)
 			return
 		}
 
+		metrics.ObserveUpload(libraryName, file.Size)
+
 		// Return relative path for client use
 		relativePath := fmt.Sprintf("/pic/%s/%s/%s", libraryName, docID, filename)
 		c.JSON(http.StatusOK, gin.H{
@@ -104,17 +132,27 @@ func GetImage(docRoot string) gin.HandlerFunc {
 		
 		// Validate parameters
 		if libraryName == "" || docID == "" || filename == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Library, document ID, and filename are required"})
+			errorResponse(c, apierror.New(apierror.InvalidRequest).With("reason", "library, document ID and filename are required"))
+			return
+		}
+		if !store.ValidDir(libraryName) || !store.Exists(docRoot, libraryName) {
+			errorResponse(c, apierror.New(apierror.LibraryNotFound).With("library", libraryName))
 			return
 		}
 		
+		// Only plain names inside a pic/<docid> folder can be images
+		if !validImageDir(docID) || !validImageName(filename) {
+			errorResponse(c, apierror.New(apierror.ImageNotFound))
+			return
+		}
+
 		// Construct the file path
 		libraryPath := filepath.Join(docRoot, libraryName)
 		imagePath := filepath.Join(libraryPath, "pic", docID, filename)
 		
 		// Check if file exists
-		if _, err := os.Stat(imagePath); os.IsNotExist(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		if info, err := os.Stat(imagePath); err != nil || !info.Mode().IsRegular() {
+			errorResponse(c, apierror.New(apierror.ImageNotFound))
 			return
 		}
 		
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"main/apierror"
+	"main/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RenderDocument returns a document with its [[Page Title]] and [[#id]] links
+// resolved to /document?id= links. Unresolved and ambiguous links stay as
+// written and are listed in wiki_links.
+func RenderDocument(documents service.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		docID := c.Query("id")
+		if docID == "" {
+			errorResponse(c, apierror.New(apierror.DocumentIDRequired))
+			return
+		}
+		// IDs that are not numbers cannot match any document
+		id, err := strconv.ParseInt(docID, 10, 64)
+		if err != nil {
+			errorResponse(c, apierror.New(apierror.DocumentNotFound))
+			return
+		}
+
+		doc, err := documents.Render(c.Request.Context(), libraryName, id)
+		if err != nil {
+			serviceError(c, "Failed to render document", err, "document_id", id)
+			return
+		}
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// RenderLibrary returns every document of a library with its wiki links
+// resolved, for exports that would otherwise render them one by one
+func RenderLibrary(documents service.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		docs, err := documents.RenderAll(c.Request.Context(), libraryName)
+		if err != nil {
+			serviceError(c, "Failed to render documents", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"documents": docs})
+	}
+}
+
+/*
+	{"id": 1, "parent_id": 0}
+*/
+// CreateStubDocuments creates an empty document for every [[Page Title]] link
+// of a document that matches no document
+func CreateStubDocuments(documents service.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		libraryName := c.Query("library")
+		if libraryName == "" {
+			errorResponse(c, apierror.New(apierror.LibraryRequired))
+			return
+		}
+
+		type StubRequest struct {
+			ID       int64 `json:"id"`
+			ParentID int64 `json:"parent_id"` // Parent of the stubs, 0 for the root
+		}
+
+		var req StubRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+
+		ids, err := documents.CreateStubs(c.Request.Context(), libraryName, req.ID, req.ParentID)
+		if err != nil {
+			serviceError(c, "Failed to create stub documents", err, "document_id", req.ID)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Stub documents created", "ids": ids})
+	}
+}
@@ -1,30 +1,225 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"main/cli"
+	"main/config"
 	"main/router"
+	"main/server"
+	"main/store"
+	"main/web"
+
+	"github.com/gin-gonic/gin"
 )
 
-type Config struct {
-	DocRoot string `yaml:"doc_root"`
+func main() {
+	os.Exit(run(os.Args[1:]))
 }
 
-func main() {
+// run dispatches to the admin subcommands, or starts the server when the first
+// argument is "serve" or a flag, and returns the process exit status
+func run(args []string) int {
+	if len(args) > 0 && args[0] == "serve" {
+		return serve(args[1:])
+	}
+	if len(args) > 0 && cli.IsCommand(args[0]) {
+		return cli.Run(args, os.Stdin, os.Stdout, os.Stderr)
+	}
+	return serve(args)
+}
+
+// serve starts the server and returns the process exit status once it has stopped
+func serve(args []string) int {
 	// Define command line flags
-	dirRootFlag := flag.String("dir", ".", "Document root directory path")
-	portFlag := flag.Int("port", 8080, "Port to run the server on")
-	
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configFlag := flags.String("config", config.DefaultPath, "Configuration file path")
+	dirRootFlag := flags.String("dir", ".", "Document root directory path")
+	portFlag := flags.Int("port", 8080, "Port to run the server on")
+	listenFlag := flags.String("listen", ":8080", "Address to listen on, overrides -port")
+
 	// Parse command line arguments
-	flag.Parse()
-	
-	// Use the provided values
-	dirRoot := *dirRootFlag
-	port := *portFlag
-	
-	fmt.Printf("Starting server with document root: %s on port: %d\n", dirRoot, port)
-	
-	// Initialize router with the document root path
-	r := router.SetupRouter(nil, dirRoot)
-	r.Run(fmt.Sprintf(":%d", port))
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unknown command %q, run doc_admin help for the list of commands\n", flags.Arg(0))
+		return 2
+	}
+
+	// Remember which flags were given explicitly, only those override the config
+	setFlags := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	// Load the config file, then environment variables, then flags
+	cfg, err := config.Load(*configFlag, setFlags["config"])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if setFlags["dir"] {
+		cfg.DocRoot = *dirRootFlag
+	}
+	if setFlags["port"] {
+		cfg.Listen = fmt.Sprintf(":%d", *portFlag)
+	}
+	if setFlags["listen"] {
+		cfg.Listen = *listenFlag
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		return 1
+	}
+	if cfg.Frontend.Embedded && cfg.Frontend.Dir == "" && web.Embedded() == nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:\nfrontend: embedded is set but the binary was built without -tags embedui")
+		return 1
+	}
+
+	// Send structured logs to the configured file, stdout otherwise
+	var logOutput io.Writer = os.Stdout
+	if cfg.Log.File != "" {
+		logFile, err := os.OpenFile(cfg.Log.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open log file: %v\n", err)
+			return 1
+		}
+		defer logFile.Close()
+		logOutput = logFile
+	}
+	slog.SetDefault(newLogger(cfg.Log, logOutput))
+	gin.DefaultWriter = logOutput
+	gin.DefaultErrorWriter = logOutput
+
+	slog.Info("starting server", "doc_root", cfg.DocRoot, "listen", cfg.Listen, "tls", cfg.TLS.Enabled())
+
+	// Initialize router with the loaded configuration
+	srv := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           router.New(cfg),
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	if cfg.TLS.Enabled() {
+		reloader, err := server.NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			slog.Error("failed to load TLS certificate", "error", err)
+			return 1
+		}
+		srv.TLSConfig = reloader.TLSConfig()
+		go func() {
+			// Certificates come from TLSConfig.GetCertificate
+			serveErr <- srv.ListenAndServeTLS("", "")
+		}()
+	} else {
+		go func() {
+			serveErr <- srv.ListenAndServe()
+		}()
+	}
+
+	// Optional plain HTTP listener that only redirects to HTTPS
+	var redirectSrv *http.Server
+	if cfg.TLS.RedirectListen != "" {
+		redirectSrv = &http.Server{
+			Addr:              cfg.TLS.RedirectListen,
+			Handler:           server.RedirectHandler(cfg.Listen),
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+			IdleTimeout:       cfg.Server.IdleTimeout,
+		}
+		slog.Info("redirecting HTTP to HTTPS", "listen", cfg.TLS.RedirectListen)
+		go func() {
+			serveErr <- redirectSrv.ListenAndServe()
+		}()
+	}
+
+	// Wait for a termination signal or for the server to fail on its own
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serveErr:
+		slog.Error("server stopped", "error", err)
+		srv.Close()
+		if redirectSrv != nil {
+			redirectSrv.Close()
+		}
+		// Close does not wait for running handlers, which may still hold a library
+		if !closeLibraries(cfg.Server.ShutdownTimeout) {
+			slog.Error("requests still hold library databases, exiting without closing them")
+		}
+		return 1
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("shutting down, waiting for in-flight requests", "timeout", cfg.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if redirectSrv != nil {
+		redirectSrv.Shutdown(shutdownCtx)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// Requests that are still running hold their library, waiting for
+		// them in CloseAll would hang past the timeout
+		slog.Error("shutdown did not complete cleanly, exiting without closing library databases", "error", err)
+		return 1
+	}
+	store.CloseAll()
+
+	slog.Info("server stopped")
+	return 0
+}
+
+// closeLibraries closes all library databases and reports whether it
+// finished within timeout
+func closeLibraries(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		store.CloseAll()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// newLogger creates the structured logger for the configured level and format
+func newLogger(cfg config.LogConfig, w io.Writer) *slog.Logger {
+	var level slog.Level
+	switch cfg.Level {
+	case "debug":
+		level = slog.LevelDebug
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		level = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	if cfg.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
 }
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric name
+const namespace = "doc_admin"
+
+// Registry holds all metrics exposed at /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	uploadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes of uploaded images by library.",
+	}, []string{"library"})
+
+	dbOpenDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_open_duration_seconds",
+		Help:      "Time to open a library database, including migrations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"library"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Library database statement latency by library and operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"library", "op"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		uploadBytes,
+		dbOpenDuration,
+		dbQueryDuration,
+	)
+}
+
+// Middleware records request counts and latency. Routes are labelled with
+// their pattern (e.g. /api/pic/:library/:docid/:filename) to keep the number
+// of series bounded; unmatched requests are labelled "unmatched".
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() gin.HandlerFunc {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	return gin.WrapH(h)
+}
+
+// ObserveUpload adds the size of an uploaded file
+func ObserveUpload(library string, bytes int64) {
+	uploadBytes.WithLabelValues(library).Add(float64(bytes))
+}
+
+// ObserveDBOpen records how long opening a library database took
+func ObserveDBOpen(library string, d time.Duration) {
+	dbOpenDuration.WithLabelValues(library).Observe(d.Seconds())
+}
+
+// ObserveDBQuery records the latency of a single statement; op is "query",
+// "exec" or "begin"
+func ObserveDBQuery(library, op string, d time.Duration) {
+	dbQueryDuration.WithLabelValues(library, op).Observe(d.Seconds())
+}
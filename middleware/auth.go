@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"main/apierror"
+	"main/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Auth returns a middleware enforcing the configured authentication.
+// It is a no-op when auth type is "none".
+func Auth(cfg config.AuthConfig) gin.HandlerFunc {
+	switch cfg.Type {
+	case "basic":
+		return func(c *gin.Context) {
+			user, pass, ok := c.Request.BasicAuth()
+			if ok {
+				if expected, found := cfg.Users[user]; found && secureEqual(pass, expected) {
+					c.Set(gin.AuthUserKey, user)
+					c.Next()
+					return
+				}
+			}
+			c.Header("WWW-Authenticate", `Basic realm="doc_admin"`)
+			apierror.Abort(c, GetRequestID(c), apierror.New(apierror.Unauthorized))
+		}
+	case "token":
+		return func(c *gin.Context) {
+			token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if found {
+				for _, expected := range cfg.Tokens {
+					if secureEqual(token, expected) {
+						c.Next()
+						return
+					}
+				}
+			}
+			apierror.Abort(c, GetRequestID(c), apierror.New(apierror.Unauthorized))
+		}
+	default:
+		return func(c *gin.Context) { c.Next() }
+	}
+}
+
+// secureEqual compares secrets in constant time
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"main/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxBodySize rejects requests whose body is larger than limit bytes.
+// Requests that announce their size are rejected up front; the body of all
+// other requests is capped so reading past the limit fails.
+func MaxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			apierror.Abort(c, GetRequestID(c), apierror.New(apierror.PayloadTooLarge).With("limit", limit))
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
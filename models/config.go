@@ -7,3 +7,14 @@ type Config struct {
 	Key      string `json:"key"`
 	Value    string `json:"value"`
 }
+
+// ConfigField describes a known configuration entry and how its value is validated
+type ConfigField struct {
+	Name        string   `json:"name"`
+	Key         string   `json:"key"`
+	Type        string   `json:"type"` // "string", "bool", "int" or "date"
+	Default     string   `json:"default"`
+	Allowed     []string `json:"allowed,omitempty"`   // Allowed values, any value if empty
+	ReadOnly    bool     `json:"read_only,omitempty"` // Managed by a dedicated endpoint
+	Description string   `json:"description"`
+}
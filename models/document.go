@@ -5,4 +5,10 @@ type Document struct {
 	Title    string `json:"title"`
 	Content  string `json:"content"`
 	ParentID int64  `json:"parent_id"` // 用于树状结构
+	// Set by the server, ignored on create
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+	// Not returned by the tree
+	Tags []string          `json:"tags,omitempty"`
+	Meta map[string]string `json:"meta,omitempty"` // Custom fields, see models.MetaField
 }
@@ -0,0 +1,10 @@
+package models
+
+// Library describes a library directory under the document root
+type Library struct {
+	Name     string `json:"name"`               // Display name, blog.name config
+	Path     string `json:"path"`               // Path of the library directory
+	Dir      string `json:"dir,omitempty"`      // Directory name under the document root
+	Archived string `json:"archived,omitempty"` // "true" for archived libraries
+	Template string `json:"template,omitempty"` // "true" for template libraries
+}
@@ -0,0 +1,43 @@
+package models
+
+// DocumentRef names a document without its content
+type DocumentRef struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+// Link is an internal link from the content of one document to another
+type Link struct {
+	Source int64 `json:"source"`
+	Target int64 `json:"target"`
+	Broken bool  `json:"broken,omitempty"` // The target does not exist
+}
+
+// LinkGraph holds the documents of a library and the links between them
+type LinkGraph struct {
+	Nodes []DocumentRef `json:"nodes"`
+	Links []Link        `json:"links"`
+}
+
+// States of a wiki link
+const (
+	WikiLinkResolved   = "resolved"   // Names exactly one document
+	WikiLinkUnresolved = "unresolved" // Names no document
+	WikiLinkAmbiguous  = "ambiguous"  // Names several documents with the same title
+)
+
+// WikiLink is a [[Page Title]] or [[#id]] link in the content of a document
+type WikiLink struct {
+	Target     string  `json:"target"` // Title or #id as written
+	Status     string  `json:"status"`
+	ID         int64   `json:"id,omitempty"`         // Resolved document
+	Candidates []int64 `json:"candidates,omitempty"` // Documents of an ambiguous title
+}
+
+// RenderedDocument is a document with its wiki links resolved
+type RenderedDocument struct {
+	ID        int64      `json:"id"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	WikiLinks []WikiLink `json:"wiki_links"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// MetaField is a document metadata field of a library's schema, defined by
+// the config entry meta.<key> = <type>[:<allowed>,<allowed>...]
+type MetaField struct {
+	Key     string   `json:"key"`
+	Type    string   `json:"type"`              // "string", "bool", "int" or "date"
+	Allowed []string `json:"allowed,omitempty"` // Allowed values, any value if empty
+}
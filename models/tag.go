@@ -0,0 +1,7 @@
+package models
+
+// Tag is a tag of a library with the number of documents that carry it
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,105 @@
+package router_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type batchResult struct {
+	Applied int              `json:"applied"`
+	IDs     map[string]int64 `json:"ids"`
+	Deleted []int64          `json:"deleted"`
+}
+
+func TestDocumentBatch(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	page := s.createDocument("lib", "Page", "", 0)
+	old := s.createDocument("lib", "Old", "", 0)
+	oldChild := s.createDocument("lib", "Old child", "", old)
+	if err := os.MkdirAll(filepath.Join(s.root, "lib", "pic", itoa(oldChild)), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var res batchResult
+	s.ok("POST", "/api/document/batch?library=lib", map[string]any{"operations": []map[string]any{
+		{"op": "create", "temp_id": "section", "title": "Section"},
+		{"op": "create", "temp_id": "sub", "title": "Sub", "content": "text", "parent_temp_id": "section"},
+		{"op": "move", "id": page, "parent_temp_id": "sub"},
+		{"op": "update", "id": page, "title": "Moved page"},
+		{"op": "update", "temp_id": "section", "content": "intro"},
+		{"op": "delete", "id": old},
+	}}, &res)
+	if res.Applied != 6 || len(res.IDs) != 2 || len(res.Deleted) != 2 {
+		t.Fatalf("got %+v", res)
+	}
+
+	section := s.getDocument("lib", res.IDs["section"])
+	sub := s.getDocument("lib", res.IDs["sub"])
+	moved := s.getDocument("lib", page)
+	if section.Title != "Section" || section.Content != "intro" || section.ParentID != 0 {
+		t.Errorf("section %+v", section)
+	}
+	if sub.ParentID != section.ID || sub.Content != "text" {
+		t.Errorf("sub %+v", sub)
+	}
+	if moved.ParentID != sub.ID || moved.Title != "Moved page" {
+		t.Errorf("page %+v", moved)
+	}
+
+	// The delete took the child and its images along
+	expectError(t, s.do("GET", "/api/document?library=lib&id="+itoa(oldChild), nil), http.StatusNotFound, "DOCUMENT_NOT_FOUND")
+	if _, err := os.Stat(filepath.Join(s.root, "lib", "pic", itoa(oldChild))); !os.IsNotExist(err) {
+		t.Errorf("images of deleted child left behind: %v", err)
+	}
+}
+
+func TestDocumentBatchAtomic(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	a := s.createDocument("lib", "A", "", 0)
+	b := s.createDocument("lib", "B", "", a)
+
+	tests := []struct {
+		name      string
+		ops       []map[string]any
+		status    int
+		code      string
+		operation int
+	}{
+		{"unknown op", []map[string]any{{"op": "rename", "id": a}}, http.StatusBadRequest, "INVALID_REQUEST", 0},
+		{"missing document", []map[string]any{{"op": "create", "title": "x"}, {"op": "update", "id": 99, "title": "x"}}, http.StatusNotFound, "DOCUMENT_NOT_FOUND", 1},
+		{"missing parent", []map[string]any{{"op": "move", "id": a, "parent_id": 99}}, http.StatusNotFound, "PARENT_NOT_FOUND", 0},
+		{"cycle", []map[string]any{{"op": "update", "id": a, "title": "changed"}, {"op": "move", "id": a, "parent_id": b}}, http.StatusBadRequest, "CYCLE_DETECTED", 1},
+		{"no id", []map[string]any{{"op": "delete"}}, http.StatusBadRequest, "DOCUMENT_ID_REQUIRED", 0},
+		{"no changes", []map[string]any{{"op": "update", "id": a, "title": ""}}, http.StatusBadRequest, "NO_CHANGES", 0},
+		{"unknown temp_id", []map[string]any{{"op": "create", "title": "x", "parent_temp_id": "later"}, {"op": "create", "temp_id": "later"}}, http.StatusBadRequest, "INVALID_REQUEST", 0},
+		{"duplicate temp_id", []map[string]any{{"op": "create", "temp_id": "x"}, {"op": "create", "temp_id": "x"}}, http.StatusBadRequest, "INVALID_REQUEST", 1},
+		{"id and temp_id", []map[string]any{{"op": "create", "temp_id": "x"}, {"op": "delete", "id": a, "temp_id": "x"}}, http.StatusBadRequest, "INVALID_REQUEST", 1},
+		{"deleted earlier", []map[string]any{{"op": "delete", "id": a}, {"op": "update", "id": b, "title": "x"}}, http.StatusNotFound, "DOCUMENT_NOT_FOUND", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := expectError(t, s.do("POST", "/api/document/batch?library=lib", map[string]any{"operations": tt.ops}), tt.status, tt.code)
+			if env.Error.Details["operation"] != float64(tt.operation) {
+				t.Errorf("details %v, want operation %d", env.Error.Details, tt.operation)
+			}
+		})
+	}
+
+	// None of the failed batches left anything behind
+	var tree []document
+	s.ok("GET", "/api/document/tree?library=lib", nil, &tree)
+	if len(tree) != 2 {
+		t.Errorf("tree %+v", tree)
+	}
+	if doc := s.getDocument("lib", a); doc.Title != "A" || doc.ParentID != 0 {
+		t.Errorf("A %+v", doc)
+	}
+
+	expectError(t, s.do("POST", "/api/document/batch?library=lib", map[string]any{"operations": []any{}}), http.StatusBadRequest, "NO_CHANGES")
+	s.ok("POST", "/api/library/archive?library=lib", map[string]any{}, nil)
+	expectError(t, s.do("POST", "/api/document/batch?library=lib", map[string]any{"operations": []map[string]any{{"op": "delete", "id": a}}}), http.StatusForbidden, "LIBRARY_ARCHIVED")
+}
@@ -0,0 +1,165 @@
+package router_test
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"main/cli"
+)
+
+// run runs a top-level CLI command against the test server's document root
+func (s *testServer) run(command string, args ...string) string {
+	s.t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append([]string{command, "-dir", s.root}, args...)
+	if code := cli.Run(args, strings.NewReader(""), &stdout, &stderr); code != 0 {
+		s.t.Fatalf("%v: exit code %d: %s", args, code, stderr.String())
+	}
+	return stdout.String()
+}
+
+func TestExportImportKeepsTagsAndMeta(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("src")
+	s.createLibrary("dst")
+	s.ok("POST", "/api/library/config?library=src", map[string]any{"name": "meta", "key": "status", "value": "string:draft,done"}, nil)
+	var created struct{ ID int64 }
+	s.ok("POST", "/api/document/create?library=src", map[string]any{"title": "Doc", "tags": []string{"go", "api"}, "meta": map[string]string{"status": "done"}}, &created)
+
+	file := filepath.Join(t.TempDir(), "src.json")
+	s.run("export", "-library", "src", "-o", file)
+	s.run("import", "-library", "dst", file)
+
+	var tree []document
+	s.ok("GET", "/api/document/tree?library=dst", nil, &tree)
+	if len(tree) != 1 {
+		t.Fatalf("imported %v", tree)
+	}
+	if got := s.documentTags("dst", tree[0].ID); !slices.Equal(got, []string{"api", "go"}) {
+		t.Errorf("tags %v", got)
+	}
+	// The schema is imported before the documents that need it
+	if got := s.documentMeta("dst", tree[0].ID); !maps.Equal(got, map[string]string{"status": "done"}) {
+		t.Errorf("meta %v", got)
+	}
+}
+
+func TestImportRewritesLinks(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("src")
+	s.createLibrary("dst")
+	s.createDocument("dst", "Existing", "", 0)
+	a := s.createDocument("src", "A", "", 0)
+	s.createDocument("src", "B", "[a](/document?x=1&id="+itoa(a)+") [gone](/document?id=99)", 0)
+
+	file := filepath.Join(t.TempDir(), "src.json")
+	s.run("export", "-library", "src", "-o", file)
+	s.run("import", "-library", "dst", file)
+
+	ids := make(map[string]int64)
+	var tree []document
+	s.ok("GET", "/api/document/tree?library=dst", nil, &tree)
+	for _, doc := range tree {
+		ids[doc.Title] = doc.ID
+	}
+	if ids["A"] == a {
+		t.Fatalf("imported A kept its ID %d", a)
+	}
+	if got, want := s.content("dst", ids["B"]), "[a](/document?x=1&id="+itoa(ids["A"])+") [gone](/document?id=99)"; got != want {
+		t.Errorf("imported content %q, want %q", got, want)
+	}
+	if got := s.backlinks("dst", ids["A"]); !slices.Equal(got, []int64{ids["B"]}) {
+		t.Errorf("backlinks %v", got)
+	}
+}
+
+func TestImportResolvedExport(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("src")
+	s.createLibrary("dst")
+	s.createDocument("dst", "Existing", "", 0)
+	a := s.createDocument("src", "A", "", 0)
+	s.createDocument("src", "B", "[[A]] [[#"+itoa(a)+"|first]]", 0)
+	s.createDocument("src", "C", "[[#"+itoa(a)+"]]", 0)
+
+	// Resolved links carry source IDs, unresolved [[#id]] links too
+	resolved := filepath.Join(t.TempDir(), "resolved.json")
+	s.run("export", "-library", "src", "-resolve-links", "-o", resolved)
+	s.run("import", "-library", "dst", resolved)
+	plain := filepath.Join(t.TempDir(), "plain.json")
+	s.run("export", "-library", "src", "-o", plain)
+	s.run("import", "-library", "dst", plain)
+
+	var tree []document
+	s.ok("GET", "/api/document/tree?library=dst", nil, &tree)
+	if len(tree) != 7 {
+		t.Fatalf("imported %v", tree)
+	}
+	// Both imports follow the order of the export, A first
+	first, second := tree[1].ID, tree[4].ID
+	wants := []string{
+		"[A](/document?id=" + itoa(first) + ") [first](/document?id=" + itoa(first) + ")",
+		"[A](/document?id=" + itoa(first) + ")",
+		"[[A]] [[#" + itoa(second) + "|first]]",
+		"[[#" + itoa(second) + "]]",
+	}
+	for i, id := range []int64{tree[2].ID, tree[3].ID, tree[5].ID, tree[6].ID} {
+		if got := s.content("dst", id); got != wants[i] {
+			t.Errorf("document %d: content %q, want %q", id, got, wants[i])
+		}
+	}
+}
+
+func TestImportFailureIsUndone(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("dst")
+	existing := s.createDocument("dst", "Existing", "", 0)
+	s.ok("POST", "/api/library/config?library=dst", map[string]any{"name": "theme", "key": "color", "value": "blue"}, nil)
+
+	// The last document fails validation after the others were created
+	export := `{"config": {"blog": {"template": "true"}, "theme": {"color": "red", "font": "serif"}}, "documents": [
+		{"id": 1, "title": "A"},
+		{"id": 2, "title": "B", "parent_id": 1},
+		{"id": 3, "title": "Bad", "parent_id": 2, "meta": {"bad key": "x"}}]}`
+	file := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(file, []byte(export), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := cli.Run([]string{"import", "-dir", s.root, "-library", "dst", file}, strings.NewReader(""), &stdout, &stderr); code == 0 {
+		t.Fatalf("import succeeded: %s", stdout.String())
+	}
+
+	var tree []document
+	s.ok("GET", "/api/document/tree?library=dst", nil, &tree)
+	if len(tree) != 1 || tree[0].ID != existing {
+		t.Errorf("documents after failed import %v", tree)
+	}
+	if got := s.config("dst")["theme"]; !maps.Equal(got, map[string]string{"color": "blue"}) {
+		t.Errorf("theme after failed import %v", got)
+	}
+}
+
+func TestImportTemplateMarker(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("src")
+	s.createLibrary("dst")
+	s.createLibrary("tpl")
+	s.ok("POST", "/api/library/config?library=src", map[string]any{"name": "blog", "key": "template", "value": "true"}, nil)
+
+	file := filepath.Join(t.TempDir(), "src.json")
+	s.run("export", "-library", "src", "-o", file)
+	s.run("import", "-library", "dst", file)
+	s.run("import", "-library", "tpl", "-template", file)
+	if got := s.config("dst")["blog"]["template"]; got != "false" {
+		t.Errorf("template marker was imported: %q", got)
+	}
+	if got := s.config("tpl")["blog"]["template"]; got != "true" {
+		t.Errorf("template marker was not imported with -template: %q", got)
+	}
+}
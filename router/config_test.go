@@ -0,0 +1,197 @@
+package router_test
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"main/models"
+	"main/service"
+)
+
+func (s *testServer) config(library string) map[string]map[string]string {
+	s.t.Helper()
+	var res struct{ Config map[string]map[string]string }
+	s.ok("GET", "/api/library/config?library="+library, nil, &res)
+	return res.Config
+}
+
+func TestConfig(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+
+	// Unset schema fields are filled in with their defaults
+	config := s.config("lib")
+	if config["blog"]["name"] != "lib" || config["blog"]["archived"] != "false" || config["blog"]["template"] != "false" {
+		t.Errorf("initial config %v", config)
+	}
+
+	s.ok("POST", "/api/library/config?library=lib", map[string]any{"name": "theme", "key": "color", "value": "blue"}, nil)
+	s.ok("POST", "/api/library/config?library=lib", map[string]any{"name": "theme", "key": "color", "value": "red"}, nil)
+	if got := s.config("lib")["theme"]["color"]; got != "red" {
+		t.Errorf("theme.color = %q", got)
+	}
+
+	var res struct{ Deleted bool }
+	s.ok("POST", "/api/library/config/delete?library=lib", map[string]any{"name": "theme", "key": "color"}, &res)
+	if !res.Deleted {
+		t.Error("delete reported nothing deleted")
+	}
+	s.ok("POST", "/api/library/config/delete?library=lib", map[string]any{"name": "theme", "key": "color"}, &res)
+	if res.Deleted {
+		t.Error("second delete reported a deletion")
+	}
+	if _, ok := s.config("lib")["theme"]; ok {
+		t.Error("theme still set")
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+
+	tests := []struct {
+		name, key, value string
+		status           int
+		code             string
+	}{
+		{"", "color", "x", http.StatusBadRequest, "CONFIG_KEY_REQUIRED"},
+		{"theme", "", "x", http.StatusBadRequest, "CONFIG_KEY_REQUIRED"},
+		{"blog", "unknown", "x", http.StatusBadRequest, "CONFIG_KEY_UNKNOWN"},
+		{"blog", "archived", "true", http.StatusBadRequest, "CONFIG_KEY_READ_ONLY"},
+		{"blog", "template", "yes", http.StatusBadRequest, "CONFIG_VALUE_INVALID"},
+	}
+	for _, tt := range tests {
+		env := expectError(t, s.do("POST", "/api/library/config?library=lib", map[string]any{"name": tt.name, "key": tt.key, "value": tt.value}), tt.status, tt.code)
+		if tt.name != "" && tt.key != "" && (env.Error.Details["name"] != tt.name || env.Error.Details["key"] != tt.key) {
+			t.Errorf("%s.%s: details %v", tt.name, tt.key, env.Error.Details)
+		}
+	}
+	expectError(t, s.do("POST", "/api/library/config/delete?library=lib", map[string]any{"name": "blog", "key": "archived"}), http.StatusBadRequest, "CONFIG_KEY_READ_ONLY")
+}
+
+func TestConfigSchema(t *testing.T) {
+	s := newTestServer(t)
+	var res struct {
+		Fields []struct{ Name, Key, Type string }
+	}
+	s.ok("GET", "/api/library/config/schema", nil, &res)
+	found := false
+	for _, f := range res.Fields {
+		if f.Name == "blog" && f.Key == "template" && f.Type == "bool" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("blog.template missing from %+v", res.Fields)
+	}
+}
+
+func TestConfigBatch(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	s.ok("POST", "/api/library/config?library=lib", map[string]any{"name": "theme", "key": "font", "value": "serif"}, nil)
+
+	var res struct{ Applied int }
+	s.ok("POST", "/api/library/config/batch?library=lib", map[string]any{"changes": []map[string]any{
+		{"name": "theme", "key": "color", "value": "blue"},
+		{"name": "theme", "key": "font", "delete": true},
+		{"name": "blog", "key": "name", "value": "Renamed"},
+	}}, &res)
+	if res.Applied != 3 {
+		t.Errorf("applied %d", res.Applied)
+	}
+	config := s.config("lib")
+	if config["theme"]["color"] != "blue" || config["theme"]["font"] != "" || config["blog"]["name"] != "Renamed" {
+		t.Errorf("config %v", config)
+	}
+
+	// One invalid change rejects the whole batch
+	env := expectError(t, s.do("POST", "/api/library/config/batch?library=lib", map[string]any{"changes": []map[string]any{
+		{"name": "theme", "key": "color", "value": "red"},
+		{"name": "blog", "key": "template", "value": "maybe"},
+	}}), http.StatusBadRequest, "CONFIG_VALUE_INVALID")
+	if env.Error.Details["change"] != float64(1) {
+		t.Errorf("details %v", env.Error.Details)
+	}
+	if got := s.config("lib")["theme"]["color"]; got != "blue" {
+		t.Errorf("partially applied batch, theme.color = %q", got)
+	}
+
+	expectError(t, s.do("POST", "/api/library/config/batch?library=lib", map[string]any{"changes": []any{}}), http.StatusBadRequest, "NO_CHANGES")
+}
+
+func TestConfigExportImport(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("src")
+	s.createLibrary("dst")
+	s.ok("POST", "/api/library/config?library=src", map[string]any{"name": "theme", "key": "color", "value": "blue"}, nil)
+	s.ok("POST", "/api/library/config?library=dst", map[string]any{"name": "theme", "key": "font", "value": "serif"}, nil)
+	s.ok("POST", "/api/library/archive?library=src", map[string]any{}, nil)
+
+	rec := s.do("GET", "/api/library/config/export?library=src", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Disposition"), "src-config.json") {
+		t.Fatalf("status %d, headers %v", rec.Code, rec.Header())
+	}
+	var exported map[string]map[string]string
+	decode(t, rec, &exported)
+	if exported["theme"]["color"] != "blue" || exported["blog"]["archived"] != "" {
+		t.Errorf("exported %v", exported)
+	}
+
+	yaml := s.do("GET", "/api/library/config/export?library=src&format=yaml", nil)
+	if !strings.Contains(yaml.Body.String(), "color: blue") {
+		t.Errorf("YAML export %s", yaml.Body)
+	}
+
+	// Import with replace drops keys missing from the document
+	s.ok("POST", "/api/library/config/import?library=dst&replace=true", rec.Body.String(), nil)
+	config := s.config("dst")
+	if config["theme"]["color"] != "blue" || config["theme"]["font"] != "" || config["blog"]["name"] != "src" {
+		t.Errorf("imported config %v", config)
+	}
+	// The archived flag of the source is not carried over
+	s.createDocument("dst", "Still writable", "", 0)
+
+	req := yaml.Body.String() + "extra:\n  key: value\n"
+	s.ok("POST", "/api/library/config/import?library=dst&format=yaml", req, nil)
+	if got := s.config("dst")["extra"]["key"]; got != "value" {
+		t.Errorf("extra.key = %q", got)
+	}
+
+	expectError(t, s.do("POST", "/api/library/config/import?library=dst", "not json"), http.StatusBadRequest, "INVALID_CONFIG_DOCUMENT")
+	expectError(t, s.do("POST", "/api/library/config/import?library=dst", `{"blog":{"archived":"true"}}`), http.StatusBadRequest, "CONFIG_KEY_READ_ONLY")
+	huge := `{"theme":{"color":"` + strings.Repeat("x", 2<<20) + `"}}`
+	expectError(t, s.do("POST", "/api/library/config/import?library=dst", huge), http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE")
+}
+
+// TestRegisterConfigFieldConcurrently registers fields while requests read
+// the schema, for go test -race
+func TestRegisterConfigFieldConcurrently(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	t.Cleanup(func() { service.UnregisterConfigFields("race_test") })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 200 {
+			service.RegisterConfigField(models.ConfigField{Name: "race_test", Key: "k" + strconv.Itoa(i), Type: "string"})
+		}
+	}()
+	// Keep reading until the registrations are done, so they overlap
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		s.ok("GET", "/api/library/config/schema", nil, nil)
+		s.ok("GET", "/api/library/config?library=lib", nil, nil)
+		s.do("POST", "/api/library/config?library=lib", map[string]any{"name": "race_test", "key": "k0", "value": "x"})
+	}
+
+	s.ok("POST", "/api/library/config?library=lib", map[string]any{"name": "race_test", "key": "k199", "value": "x"}, nil)
+	expectError(t, s.do("POST", "/api/library/config?library=lib", map[string]any{"name": "race_test", "key": "unknown", "value": "x"}), http.StatusBadRequest, "CONFIG_KEY_UNKNOWN")
+}
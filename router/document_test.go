@@ -0,0 +1,196 @@
+package router_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type document struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	ParentID int64  `json:"parent_id"`
+}
+
+func (s *testServer) getDocument(library string, id int64) document {
+	s.t.Helper()
+	var doc document
+	s.ok("GET", "/api/document?library="+library+"&id="+itoa(id), nil, &doc)
+	return doc
+}
+
+func TestDocumentCRUD(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+
+	root := s.createDocument("lib", "Root", "# Root", 0)
+	child := s.createDocument("lib", "Child", "text", root)
+	if root <= 0 || child <= root {
+		t.Fatalf("unexpected IDs %d, %d", root, child)
+	}
+
+	doc := s.getDocument("lib", child)
+	if doc != (document{ID: child, Title: "Child", Content: "text", ParentID: root}) {
+		t.Errorf("got %+v", doc)
+	}
+
+	var tree []document
+	s.ok("GET", "/api/document/tree?library=lib", nil, &tree)
+	if len(tree) != 2 {
+		t.Errorf("tree has %d documents, want 2", len(tree))
+	}
+
+	// Title only, content untouched
+	var res struct{ Updated bool }
+	s.ok("POST", "/api/document/update?library=lib", map[string]any{"id": child, "title": "Renamed"}, &res)
+	if !res.Updated {
+		t.Error("update reported no change")
+	}
+	if doc := s.getDocument("lib", child); doc.Title != "Renamed" || doc.Content != "text" {
+		t.Errorf("after title update: %+v", doc)
+	}
+
+	// An empty content is an update, an empty title is not
+	s.ok("POST", "/api/document/update?library=lib", map[string]any{"id": child, "title": "", "content": ""}, nil)
+	if doc := s.getDocument("lib", child); doc.Title != "Renamed" || doc.Content != "" {
+		t.Errorf("after content update: %+v", doc)
+	}
+
+	s.ok("POST", "/api/document/update-parent?library=lib", map[string]any{"id": child, "parent_id": 0}, nil)
+	if doc := s.getDocument("lib", child); doc.ParentID != 0 {
+		t.Errorf("after move: %+v", doc)
+	}
+}
+
+func TestEmptyTree(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+
+	rec := s.do("GET", "/api/document/tree?library=lib", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "[]" {
+		t.Errorf("status %d, body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestDocumentNotFound(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	s.createDocument("lib", "Doc", "", 0)
+
+	for _, id := range []string{"99", "0", "-1", "abc", "1%20OR%201=1"} {
+		expectError(t, s.do("GET", "/api/document?library=lib&id="+id, nil), http.StatusNotFound, "DOCUMENT_NOT_FOUND")
+	}
+	expectError(t, s.do("GET", "/api/document?library=lib", nil), http.StatusBadRequest, "DOCUMENT_ID_REQUIRED")
+
+	expectError(t, s.do("POST", "/api/document/update?library=lib", map[string]any{"id": 99, "title": "x"}), http.StatusNotFound, "DOCUMENT_NOT_FOUND")
+	expectError(t, s.do("POST", "/api/document/update?library=lib", map[string]any{"title": "x"}), http.StatusBadRequest, "DOCUMENT_ID_REQUIRED")
+	expectError(t, s.do("POST", "/api/document/update?library=lib", map[string]any{"id": 1}), http.StatusBadRequest, "NO_CHANGES")
+}
+
+func TestMoveCycles(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	a := s.createDocument("lib", "A", "", 0)
+	b := s.createDocument("lib", "B", "", a)
+	c := s.createDocument("lib", "C", "", b)
+
+	for _, move := range []struct{ id, parent int64 }{
+		{a, a}, // Under itself
+		{a, b}, // Under its child
+		{a, c}, // Under its grandchild
+		{b, c},
+	} {
+		env := expectError(t, s.do("POST", "/api/document/update-parent?library=lib", map[string]any{"id": move.id, "parent_id": move.parent}), http.StatusBadRequest, "CYCLE_DETECTED")
+		if env.Error.Details["id"] != float64(move.id) || env.Error.Details["parent_id"] != float64(move.parent) {
+			t.Errorf("details %v for move %v", env.Error.Details, move)
+		}
+	}
+
+	// Nothing was changed by the rejected moves
+	if doc := s.getDocument("lib", a); doc.ParentID != 0 {
+		t.Errorf("A moved to %d", doc.ParentID)
+	}
+
+	// Moving a grandchild up is fine
+	s.ok("POST", "/api/document/update-parent?library=lib", map[string]any{"id": c, "parent_id": a}, nil)
+	if doc := s.getDocument("lib", c); doc.ParentID != a {
+		t.Errorf("C has parent %d, want %d", doc.ParentID, a)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("src")
+	s.createLibrary("dst")
+	root := s.createDocument("src", "Root", "", 0)
+	child := s.createDocument("src", "Child", "![img](/api/pic/src/"+itoa(root)+"/a.png)", root)
+	s.createDocument("src", "Other", "", 0)
+	target := s.createDocument("dst", "Target", "", 0)
+
+	if err := os.MkdirAll(filepath.Join(s.root, "src", "pic", itoa(root)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.root, "src", "pic", itoa(root), "a.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var res struct {
+		ID  int64
+		IDs map[string]int64
+	}
+	s.ok("POST", "/api/document/transfer", map[string]any{"source_library": "src", "target_library": "dst", "id": root, "parent_id": target, "mode": "move"}, &res)
+	if len(res.IDs) != 2 || res.IDs[itoa(root)] != res.ID {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	newRoot := s.getDocument("dst", res.ID)
+	if newRoot.ParentID != target {
+		t.Errorf("moved root has parent %d, want %d", newRoot.ParentID, target)
+	}
+	newChild := s.getDocument("dst", res.IDs[itoa(child)])
+	if newChild.ParentID != res.ID || newChild.Content != "![img](/api/pic/dst/"+itoa(res.ID)+"/a.png)" {
+		t.Errorf("moved child %+v", newChild)
+	}
+	if _, err := os.Stat(filepath.Join(s.root, "dst", "pic", itoa(res.ID), "a.png")); err != nil {
+		t.Errorf("image not moved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(s.root, "src", "pic", itoa(root))); !os.IsNotExist(err) {
+		t.Errorf("source images left behind: %v", err)
+	}
+
+	var tree []document
+	s.ok("GET", "/api/document/tree?library=src", nil, &tree)
+	if len(tree) != 1 || tree[0].Title != "Other" {
+		t.Errorf("source library still has %+v", tree)
+	}
+}
+
+func TestTransferErrors(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("src")
+	s.createLibrary("dst")
+	id := s.createDocument("src", "Doc", "", 0)
+
+	tests := []struct {
+		name   string
+		body   map[string]any
+		status int
+		code   string
+	}{
+		{"bad mode", map[string]any{"source_library": "src", "target_library": "dst", "id": id, "mode": "link"}, http.StatusBadRequest, "INVALID_REQUEST"},
+		{"no library", map[string]any{"source_library": "src", "id": id}, http.StatusBadRequest, "LIBRARY_REQUIRED"},
+		{"same library", map[string]any{"source_library": "src", "target_library": "src", "id": id}, http.StatusBadRequest, "INVALID_REQUEST"},
+		{"no id", map[string]any{"source_library": "src", "target_library": "dst"}, http.StatusBadRequest, "DOCUMENT_ID_REQUIRED"},
+		{"missing library", map[string]any{"source_library": "src", "target_library": "nope", "id": id}, http.StatusNotFound, "LIBRARY_NOT_FOUND"},
+		{"traversal", map[string]any{"source_library": "src", "target_library": "../dst", "id": id}, http.StatusNotFound, "LIBRARY_NOT_FOUND"},
+		{"missing document", map[string]any{"source_library": "src", "target_library": "dst", "id": 99}, http.StatusNotFound, "DOCUMENT_NOT_FOUND"},
+		{"missing parent", map[string]any{"source_library": "src", "target_library": "dst", "id": id, "parent_id": 99}, http.StatusNotFound, "PARENT_NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, s.do("POST", "/api/document/transfer", tt.body), tt.status, tt.code)
+		})
+	}
+}
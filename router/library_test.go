@@ -0,0 +1,284 @@
+package router_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+type library struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Dir      string `json:"dir"`
+	Archived string `json:"archived"`
+	Template string `json:"template"`
+}
+
+func (s *testServer) listLibraries(archived bool) map[string]library {
+	s.t.Helper()
+	path := "/api/library/list"
+	if archived {
+		path += "?archived=true"
+	}
+	var res struct{ Libraries []library }
+	s.ok("GET", path, nil, &res)
+	libraries := make(map[string]library)
+	for _, l := range res.Libraries {
+		libraries[l.Dir] = l
+	}
+	return libraries
+}
+
+func TestCreateLibrary(t *testing.T) {
+	s := newTestServer(t)
+
+	var res struct{ Name, Path string }
+	s.ok("POST", "/api/library/create", map[string]any{"name": "My Book", "base_path": filepath.Join(s.root, "book")}, &res)
+	if res.Name != "My Book" || res.Path != filepath.Join(s.root, "book") {
+		t.Errorf("got %+v", res)
+	}
+	for _, path := range []string{"blog.db", "pic"} {
+		if _, err := os.Stat(filepath.Join(s.root, "book", path)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	libraries := s.listLibraries(false)
+	if libraries["book"].Name != "My Book" {
+		t.Errorf("list: %+v", libraries)
+	}
+
+	expectError(t, s.do("POST", "/api/library/create", map[string]any{"name": "Again", "base_path": filepath.Join(s.root, "book")}), http.StatusConflict, "LIBRARY_EXISTS")
+	expectError(t, s.do("POST", "/api/library/create", map[string]any{}), http.StatusBadRequest, "INVALID_REQUEST")
+}
+
+func TestCreateLibraryWithoutPath(t *testing.T) {
+	s := newTestServer(t)
+
+	// The library goes under the document root, not the working directory
+	var res struct{ Name, Path string }
+	s.ok("POST", "/api/library/create", map[string]any{"name": "notes"}, &res)
+	if res.Path != filepath.Join(s.root, "notes") {
+		t.Errorf("got %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(s.root, "notes", "blog.db")); err != nil {
+		t.Error(err)
+	}
+	if _, ok := s.listLibraries(false)["notes"]; !ok {
+		t.Errorf("not listed: %v", s.listLibraries(false))
+	}
+	s.createDocument("notes", "Doc", "", 0)
+
+	expectError(t, s.do("POST", "/api/library/create", map[string]any{"name": "notes"}), http.StatusConflict, "LIBRARY_EXISTS")
+	expectError(t, s.do("POST", "/api/library/create", map[string]any{"name": "../escape"}), http.StatusBadRequest, "INVALID_LIBRARY_NAME")
+}
+
+func TestCreateFromTemplate(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("tpl")
+	id := s.createDocument("tpl", "Start here", "![](/api/pic/tpl/1/logo.png)", 0)
+
+	expectError(t, s.do("POST", "/api/library/create", map[string]any{"name": "New", "base_path": filepath.Join(s.root, "new"), "template": "tpl"}), http.StatusBadRequest, "NOT_A_TEMPLATE")
+	expectError(t, s.do("POST", "/api/library/create", map[string]any{"name": "New", "base_path": filepath.Join(s.root, "new"), "template": "../tpl"}), http.StatusNotFound, "LIBRARY_NOT_FOUND")
+
+	s.ok("POST", "/api/library/config?library=tpl", map[string]any{"name": "blog", "key": "template", "value": "true"}, nil)
+	if !(s.listLibraries(false)["tpl"].Template == "true") {
+		t.Error("template flag not listed")
+	}
+
+	s.ok("POST", "/api/library/create", map[string]any{"name": "New", "base_path": filepath.Join(s.root, "new"), "template": "tpl"}, nil)
+	doc := s.getDocument("new", id)
+	if doc.Title != "Start here" || doc.Content != "![](/api/pic/new/1/logo.png)" {
+		t.Errorf("copied document %+v", doc)
+	}
+	var res struct{ Config map[string]map[string]string }
+	s.ok("GET", "/api/library/config?library=new", nil, &res)
+	if res.Config["blog"]["name"] != "New" || res.Config["blog"]["template"] != "false" {
+		t.Errorf("copied config %v", res.Config)
+	}
+}
+
+func TestCloneLibrary(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("src")
+	id := s.createDocument("src", "Doc", "", 0)
+
+	var res struct{ Name, Dir string }
+	s.ok("POST", "/api/library/clone", map[string]any{"source": "src", "dir": "copy"}, &res)
+	if res.Name != "copy" || res.Dir != "copy" {
+		t.Errorf("got %+v", res)
+	}
+	if doc := s.getDocument("copy", id); doc.Title != "Doc" {
+		t.Errorf("cloned document %+v", doc)
+	}
+
+	tests := []struct {
+		name   string
+		body   map[string]any
+		status int
+		code   string
+	}{
+		{"missing fields", map[string]any{"source": "src"}, http.StatusBadRequest, "INVALID_REQUEST"},
+		{"traversal target", map[string]any{"source": "src", "dir": "../escape"}, http.StatusBadRequest, "INVALID_LIBRARY_NAME"},
+		{"traversal source", map[string]any{"source": "..", "dir": "x"}, http.StatusNotFound, "LIBRARY_NOT_FOUND"},
+		{"missing source", map[string]any{"source": "nope", "dir": "x"}, http.StatusNotFound, "LIBRARY_NOT_FOUND"},
+		{"existing target", map[string]any{"source": "src", "dir": "copy"}, http.StatusConflict, "LIBRARY_EXISTS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, s.do("POST", "/api/library/clone", tt.body), tt.status, tt.code)
+		})
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(s.root), "escape")); !os.IsNotExist(err) {
+		t.Error("clone escaped the document root")
+	}
+}
+
+func TestRenameLibrary(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("old")
+	s.createLibrary("taken")
+	id := s.createDocument("old", "Doc", "", 0)
+	// Load the library into the pool so the rename has to close it
+	s.getDocument("old", id)
+
+	var res struct{ Name, Dir, Path string }
+	s.ok("POST", "/api/library/rename?library=old", map[string]any{"dir": "new", "name": "New Name"}, &res)
+	if res.Name != "New Name" || res.Dir != "new" || res.Path != filepath.Join(s.root, "new") {
+		t.Errorf("got %+v", res)
+	}
+	if doc := s.getDocument("new", id); doc.Title != "Doc" {
+		t.Errorf("document after rename %+v", doc)
+	}
+	expectError(t, s.do("GET", "/api/document/tree?library=old", nil), http.StatusNotFound, "LIBRARY_NOT_FOUND")
+	if l := s.listLibraries(false)["new"]; l.Name != "New Name" {
+		t.Errorf("listed as %+v", l)
+	}
+
+	// Changing only the directory keeps the display name
+	s.ok("POST", "/api/library/rename?library=new", map[string]any{"dir": "newer"}, &res)
+	if res.Name != "New Name" || res.Dir != "newer" {
+		t.Errorf("directory rename got %+v", res)
+	}
+	s.ok("POST", "/api/library/rename?library=newer", map[string]any{"dir": "new"}, &res)
+
+	expectError(t, s.do("POST", "/api/library/rename?library=new", map[string]any{}), http.StatusBadRequest, "NO_CHANGES")
+	// A rename that fails keeps the display name too
+	expectError(t, s.do("POST", "/api/library/rename?library=new", map[string]any{"dir": "taken", "name": "Other"}), http.StatusConflict, "LIBRARY_EXISTS")
+	if l := s.listLibraries(false)["new"]; l.Name != "New Name" {
+		t.Errorf("name after failed rename %+v", l)
+	}
+	for _, dir := range []string{"..", "../escape", `a\b`} {
+		expectError(t, s.do("POST", "/api/library/rename?library=new", map[string]any{"dir": dir}), http.StatusBadRequest, "INVALID_LIBRARY_NAME")
+	}
+}
+
+func TestArchiveLibrary(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	id := s.createDocument("lib", "Doc", "", 0)
+
+	s.ok("POST", "/api/library/archive?library=lib", map[string]any{}, nil)
+
+	if _, ok := s.listLibraries(false)["lib"]; ok {
+		t.Error("archived library listed by default")
+	}
+	if l := s.listLibraries(true)["lib"]; l.Archived != "true" {
+		t.Errorf("listed with archived=true as %+v", l)
+	}
+
+	// Reads still work, writes are rejected
+	s.getDocument("lib", id)
+	expectError(t, s.do("POST", "/api/document/create?library=lib", map[string]any{"title": "x"}), http.StatusForbidden, "LIBRARY_ARCHIVED")
+	expectError(t, s.do("POST", "/api/document/update?library=lib", map[string]any{"id": id, "title": "x"}), http.StatusForbidden, "LIBRARY_ARCHIVED")
+	expectError(t, s.do("POST", "/api/library/config?library=lib", map[string]any{"name": "blog", "key": "name", "value": "x"}), http.StatusForbidden, "LIBRARY_ARCHIVED")
+	expectError(t, s.do("POST", "/api/library/config?library=lib", map[string]any{"name": "blog", "key": "archived", "value": "false"}), http.StatusBadRequest, "CONFIG_KEY_READ_ONLY")
+
+	s.ok("POST", "/api/library/archive?library=lib", map[string]any{"archived": false}, nil)
+	s.createDocument("lib", "Writable again", "", 0)
+}
+
+func TestDeleteLibrary(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	s.createLibrary("other")
+	s.createDocument("lib", "Doc", "", 0)
+
+	rec := s.do("POST", "/api/library/delete?library=lib", map[string]any{})
+	var res struct{ Token string }
+	decode(t, rec, &res)
+	if rec.Code != http.StatusAccepted || res.Token == "" {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+
+	// The token is bound to its library
+	expectError(t, s.do("POST", "/api/library/delete?library=other", map[string]any{"token": res.Token}), http.StatusForbidden, "INVALID_CONFIRMATION_TOKEN")
+	// and single use, so the mismatch above consumed it
+	expectError(t, s.do("POST", "/api/library/delete?library=lib", map[string]any{"token": res.Token}), http.StatusForbidden, "INVALID_CONFIRMATION_TOKEN")
+
+	decode(t, s.do("POST", "/api/library/delete?library=lib", map[string]any{}), &res)
+	s.ok("POST", "/api/library/delete?library=lib", map[string]any{"token": res.Token}, nil)
+	if _, err := os.Stat(filepath.Join(s.root, "lib")); !os.IsNotExist(err) {
+		t.Errorf("library directory still exists: %v", err)
+	}
+	expectError(t, s.do("GET", "/api/document/tree?library=lib", nil), http.StatusNotFound, "LIBRARY_NOT_FOUND")
+	if _, err := os.Stat(filepath.Join(s.root, "other", "blog.db")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSameLibraryNameInTwoRoots(t *testing.T) {
+	a, b := newTestServer(t), newTestServer(t)
+	a.createLibrary("lib")
+	b.createLibrary("lib")
+	a.createDocument("lib", "Only in A", "", 0)
+
+	// Each root has its own pool entry and database handle
+	var tree []document
+	b.ok("GET", "/api/document/tree?library=lib", nil, &tree)
+	if len(tree) != 0 {
+		t.Errorf("tree of B %+v", tree)
+	}
+}
+
+// TestRenameDuringTransfer races renames against transfers between the same
+// libraries, which must never deadlock
+func TestRenameDuringTransfer(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("a")
+	s.createLibrary("b")
+	id := s.createDocument("a", "Doc", "", 0)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				s.do("POST", "/api/library/rename?library=b", map[string]any{"dir": "a"})
+			}()
+			go func() {
+				defer wg.Done()
+				s.do("POST", "/api/document/transfer", map[string]any{"source_library": "a", "target_library": "b", "id": id})
+			}()
+		}
+		wg.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		// t.Fatal would hang in the pool cleanup, which waits for the
+		// deadlocked requests
+		panic("rename and transfer deadlocked")
+	}
+}
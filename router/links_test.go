@@ -0,0 +1,167 @@
+package router_test
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type link struct {
+	Source int64 `json:"source"`
+	Target int64 `json:"target"`
+	Broken bool  `json:"broken"`
+}
+
+func (s *testServer) backlinks(library string, id int64) []int64 {
+	s.t.Helper()
+	var res struct {
+		Backlinks []struct{ ID int64 }
+	}
+	s.ok("GET", "/api/document/backlinks?library="+library+"&id="+itoa(id), nil, &res)
+	ids := []int64{}
+	for _, ref := range res.Backlinks {
+		ids = append(ids, ref.ID)
+	}
+	return ids
+}
+
+func (s *testServer) content(library string, id int64) string {
+	s.t.Helper()
+	var doc struct{ Content string }
+	s.ok("GET", "/api/document?library="+library+"&id="+itoa(id), nil, &doc)
+	return doc.Content
+}
+
+func TestBacklinks(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	target := s.createDocument("lib", "Target", "", 0)
+	ref := "/document?id=" + itoa(target)
+	b := s.createDocument("lib", "B", "see [target]("+ref+") and [again]("+ref+"#top)", 0)
+	a := s.createDocument("lib", "A", "<a href=\"/api/document?x=1&id="+itoa(target)+"\">", 0)
+	// Links into other libraries and malformed IDs are not tracked
+	s.createDocument("lib", "C", "[other](/document?library=other&id="+itoa(target)+") [id](/document?id=abc)", 0)
+
+	if got := s.backlinks("lib", target); !slices.Equal(got, []int64{a, b}) {
+		t.Errorf("backlinks %v, want %v", got, []int64{a, b})
+	}
+
+	// Saving new content replaces the links
+	s.ok("POST", "/api/document/update?library=lib", map[string]any{"id": b, "content": "no links"}, nil)
+	if got := s.backlinks("lib", target); !slices.Equal(got, []int64{a}) {
+		t.Errorf("backlinks after update %v", got)
+	}
+	s.ok("POST", "/api/document/batch?library=lib", map[string]any{"operations": []map[string]any{
+		{"op": "update", "id": b, "content": "[t](" + ref + ")"},
+		{"op": "delete", "id": a},
+	}}, nil)
+	if got := s.backlinks("lib", target); !slices.Equal(got, []int64{b}) {
+		t.Errorf("backlinks after batch %v", got)
+	}
+
+	expectError(t, s.do("GET", "/api/document/backlinks?library=lib", nil), http.StatusBadRequest, "DOCUMENT_ID_REQUIRED")
+	expectError(t, s.do("GET", "/api/document/backlinks?library=lib&id=99", nil), http.StatusNotFound, "DOCUMENT_NOT_FOUND")
+	expectError(t, s.do("GET", "/api/document/backlinks?library=lib&id=x", nil), http.StatusNotFound, "DOCUMENT_NOT_FOUND")
+}
+
+func TestBrokenLinks(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	a := s.createDocument("lib", "A", "", 0)
+
+	var created struct {
+		ID          int64
+		BrokenLinks []link `json:"broken_links"`
+	}
+	s.ok("POST", "/api/document/create?library=lib", map[string]any{"title": "B", "content": "[a](/document?id=" + itoa(a) + ") [x](/document?id=99)"}, &created)
+	if !slices.Equal(created.BrokenLinks, []link{{created.ID, 99, true}}) {
+		t.Errorf("broken links on create %v", created.BrokenLinks)
+	}
+
+	var updated struct {
+		BrokenLinks []link `json:"broken_links"`
+	}
+	s.ok("POST", "/api/document/update?library=lib", map[string]any{"id": created.ID, "content": "[a](/document?id=" + itoa(a) + ")"}, &updated)
+	if updated.BrokenLinks == nil || len(updated.BrokenLinks) != 0 {
+		t.Errorf("broken links on update %v", updated.BrokenLinks)
+	}
+
+	// Deleting a linked document reports the links that break
+	var batch struct {
+		BrokenLinks []link `json:"broken_links"`
+	}
+	s.ok("POST", "/api/document/batch?library=lib", map[string]any{"operations": []map[string]any{{"op": "delete", "id": a}}}, &batch)
+	if !slices.Equal(batch.BrokenLinks, []link{{created.ID, a, true}}) {
+		t.Errorf("broken links on delete %v", batch.BrokenLinks)
+	}
+}
+
+func TestLibraryGraph(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	a := s.createDocument("lib", "A", "", 0)
+	b := s.createDocument("lib", "B", "[a](/document?id="+itoa(a)+") [gone](/document?id=99)", a)
+	s.ok("POST", "/api/document/update?library=lib", map[string]any{"id": a, "content": "[b](/document?id=" + itoa(b) + ")"}, nil)
+
+	var graph struct {
+		Nodes []struct {
+			ID    int64
+			Title string
+		}
+		Links []link
+	}
+	s.ok("GET", "/api/library/graph?library=lib", nil, &graph)
+	if len(graph.Nodes) != 2 || graph.Nodes[0].Title != "A" {
+		t.Errorf("nodes %+v", graph.Nodes)
+	}
+	if want := []link{{a, b, false}, {b, a, false}, {b, 99, true}}; !slices.Equal(graph.Links, want) {
+		t.Errorf("links %v, want %v", graph.Links, want)
+	}
+
+	s.createLibrary("empty")
+	rec := s.do("GET", "/api/library/graph?library=empty", nil)
+	if body := rec.Body.String(); !strings.Contains(body, `"nodes":[]`) || !strings.Contains(body, `"links":[]`) {
+		t.Errorf("empty graph %s", body)
+	}
+}
+
+func TestTransferRewritesLinks(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("src")
+	s.createLibrary("dst")
+	outside := s.createDocument("src", "Outside", "", 0)
+	root := s.createDocument("src", "Root", "", 0)
+	child := s.createDocument("src", "Child", "[root](/document?id="+itoa(root)+") [top](/api/document?x=1&id="+itoa(root)+"&y=2#top)", root)
+	s.ok("POST", "/api/document/update?library=src", map[string]any{"id": root, "content": "[child](/document?id=" + itoa(child) + ") [out](/document?id=" + itoa(outside) + ") [[#" + itoa(child) + "|kid]] [[#" + itoa(outside) + "]]"}, nil)
+	s.ok("POST", "/api/document/update?library=src", map[string]any{"id": outside, "content": "[root](/document?view=full&id=" + itoa(root) + ") [[#" + itoa(root) + "]]"}, nil)
+
+	var res struct {
+		ID  int64
+		IDs map[string]int64
+	}
+	s.ok("POST", "/api/document/transfer", map[string]any{"source_library": "src", "target_library": "dst", "id": root, "mode": "move"}, &res)
+	newRoot, newChild := res.IDs[itoa(root)], res.IDs[itoa(child)]
+
+	// Links inside the subtree follow it, others point back at the source.
+	// Wiki links cannot name a library and become /document links.
+	if got, want := s.content("dst", newRoot), "[child](/document?id="+itoa(newChild)+") [out](/document?library=src&id="+itoa(outside)+") [[#"+itoa(newChild)+"|kid]] [Outside](/document?library=src&id="+itoa(outside)+")"; got != want {
+		t.Errorf("moved content %q, want %q", got, want)
+	}
+	// Only the id is replaced, other parameters stay
+	if got, want := s.content("dst", newChild), "[root](/document?id="+itoa(newRoot)+") [top](/api/document?x=1&id="+itoa(newRoot)+"&y=2#top)"; got != want {
+		t.Errorf("moved child %q, want %q", got, want)
+	}
+	if got := s.backlinks("dst", newRoot); !slices.Equal(got, []int64{newChild}) {
+		t.Errorf("backlinks in target %v", got)
+	}
+	// Links to the moved documents now point into the target library
+	if got, want := s.content("src", outside), "[root](/document?view=full&library=dst&id="+itoa(newRoot)+") [Root](/document?library=dst&id="+itoa(newRoot)+")"; got != want {
+		t.Errorf("source content %q, want %q", got, want)
+	}
+	var graph struct{ Links []link }
+	s.ok("GET", "/api/library/graph?library=src", nil, &graph)
+	if len(graph.Links) != 0 {
+		t.Errorf("source links %v", graph.Links)
+	}
+}
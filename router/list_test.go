@@ -0,0 +1,202 @@
+package router_test
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"main/store"
+)
+
+type documentPage struct {
+	Documents  []map[string]any `json:"documents"`
+	NextCursor string           `json:"next_cursor"`
+}
+
+// listAll follows the cursors of a listing and returns the IDs of all pages
+func (s *testServer) listAll(library, query string) (ids []int64, pages int) {
+	s.t.Helper()
+	cursor := ""
+	for {
+		path := "/api/document/list?library=" + library + "&" + query
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+		var page documentPage
+		s.ok("GET", path, nil, &page)
+		pages++
+		for _, doc := range page.Documents {
+			ids = append(ids, int64(doc["id"].(float64)))
+		}
+		if page.NextCursor == "" {
+			return ids, pages
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func TestListDocuments(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	root := s.createDocument("lib", "Guide", "", 0)
+	b := s.createDocument("lib", "beta", "", root)
+	a := s.createDocument("lib", "Alpha", "", root)
+	c := s.createDocument("lib", "Gamma", "", 0)
+	d := s.createDocument("lib", "Guide", "", 0) // Same title as root
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"limit=2", []int64{root, b, a, c, d}},
+		{"limit=2&order=desc", []int64{d, c, a, b, root}},
+		{"limit=2&sort=title", []int64{a, c, root, d, b}},
+		{"limit=1&sort=title&order=desc", []int64{b, d, root, c, a}},
+		{"parent_id=" + itoa(root), []int64{b, a}},
+		{"parent_id=0&sort=title", []int64{c, root, d}},
+		{"title_prefix=gu&limit=1", []int64{root, d}},
+		{"title_prefix=%25", nil},
+	}
+	for _, tt := range tests {
+		ids, _ := s.listAll("lib", tt.query)
+		if len(ids) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.query, ids, tt.want)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.query, ids, tt.want)
+				break
+			}
+		}
+	}
+
+	if _, pages := s.listAll("lib", "limit=2"); pages != 3 {
+		t.Errorf("5 documents in pages of 2 took %d pages", pages)
+	}
+	if _, pages := s.listAll("lib", "limit=5"); pages != 1 {
+		t.Errorf("a full last page is followed by an empty one")
+	}
+}
+
+func TestListDocumentsUpdatedAt(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	a := s.createDocument("lib", "A", "", 0)
+	b := s.createDocument("lib", "B", "", 0)
+
+	time.Sleep(2 * time.Millisecond)
+	s.ok("POST", "/api/document/update?library=lib", map[string]any{"id": a, "content": "new"}, nil)
+
+	var doc struct {
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+	}
+	s.ok("GET", "/api/document?library=lib&id="+itoa(a), nil, &doc)
+	if doc.CreatedAt == "" || doc.UpdatedAt <= doc.CreatedAt {
+		t.Errorf("created %q, updated %q", doc.CreatedAt, doc.UpdatedAt)
+	}
+
+	if ids, _ := s.listAll("lib", "sort=updated_at&order=desc"); len(ids) != 2 || ids[0] != a || ids[1] != b {
+		t.Errorf("by updated_at: %v", ids)
+	}
+
+	// Only A was changed at or after its update time
+	if ids, _ := s.listAll("lib", "updated_after="+url.QueryEscape(doc.UpdatedAt)); len(ids) != 1 || ids[0] != a {
+		t.Errorf("updated_after: %v", ids)
+	}
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+	if ids, _ := s.listAll("lib", "updated_before="+tomorrow); len(ids) != 2 {
+		t.Errorf("updated_before tomorrow: %v", ids)
+	}
+	if ids, _ := s.listAll("lib", "updated_after="+tomorrow); len(ids) != 0 {
+		t.Errorf("updated_after tomorrow: %v", ids)
+	}
+}
+
+func TestListDocumentsNullSortValues(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	a := s.createDocument("lib", "A", "", 0)
+	b := s.createDocument("lib", "B", "", 0)
+	c := s.createDocument("lib", "C", "", 0)
+	d := s.createDocument("lib", "D", "", 0)
+
+	// Rows written by older versions or other tools can have NULLs
+	db, err := store.OpenFile(filepath.Join(s.root, "lib"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("UPDATE documents SET title = NULL, updated_at = NULL WHERE id IN (?, ?)", b, d)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"limit=1&sort=title", []int64{b, d, a, c}},
+		{"limit=1&sort=title&order=desc", []int64{c, a, d, b}},
+		{"limit=1&sort=updated_at", []int64{b, d, a, c}},
+		{"limit=1&sort=updated_at&order=desc", []int64{c, a, d, b}},
+	}
+	for _, tt := range tests {
+		if ids, _ := s.listAll("lib", tt.query); !slices.Equal(ids, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.query, ids, tt.want)
+		}
+	}
+}
+
+func TestListDocumentsFields(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	s.createDocument("lib", "Doc", "long content", 0)
+
+	var page documentPage
+	s.ok("GET", "/api/document/list?library=lib&fields=id,title,parent_id", nil, &page)
+	if len(page.Documents) != 1 || len(page.Documents[0]) != 3 || page.Documents[0]["title"] != "Doc" || page.Documents[0]["parent_id"] != float64(0) {
+		t.Errorf("got %v", page.Documents)
+	}
+
+	s.ok("GET", "/api/document/list?library=lib", nil, &page)
+	if page.Documents[0]["content"] != "long content" || page.Documents[0]["updated_at"] == "" {
+		t.Errorf("default fields %v", page.Documents[0])
+	}
+}
+
+func TestListDocumentsErrors(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	s.createDocument("lib", "A", "", 0)
+	s.createDocument("lib", "B", "", 0)
+
+	var page documentPage
+	s.ok("GET", "/api/document/list?library=lib&limit=1", nil, &page)
+
+	for _, query := range []string{
+		"sort=content",
+		"sort=id%3BDROP%20TABLE%20documents",
+		"order=up",
+		"limit=0",
+		"limit=501",
+		"limit=x",
+		"parent_id=x",
+		"fields=id,secret",
+		"updated_after=yesterday",
+		"cursor=not-a-cursor",
+		"cursor=" + page.NextCursor + "&order=desc",
+		"cursor=" + page.NextCursor + "&sort=title",
+	} {
+		env := expectError(t, s.do("GET", "/api/document/list?library=lib&"+query, nil), http.StatusBadRequest, "INVALID_REQUEST")
+		if env.Error.Details["reason"] == nil {
+			t.Errorf("%s: no reason in %v", query, env.Error.Details)
+		}
+	}
+	expectError(t, s.do("GET", "/api/document/list", nil), http.StatusBadRequest, "LIBRARY_REQUIRED")
+	expectError(t, s.do("GET", "/api/document/list?library=nope", nil), http.StatusNotFound, "LIBRARY_NOT_FOUND")
+}
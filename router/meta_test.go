@@ -0,0 +1,136 @@
+package router_test
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func (s *testServer) documentMeta(library string, id int64) map[string]string {
+	s.t.Helper()
+	var doc struct{ Meta map[string]string }
+	s.ok("GET", "/api/document?library="+library+"&id="+itoa(id), nil, &doc)
+	return doc.Meta
+}
+
+func TestDocumentMeta(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	id := s.createDocument("lib", "Doc", "", 0)
+
+	// Without a schema any key is accepted
+	var res struct{ Meta map[string]string }
+	s.ok("POST", "/api/document/meta?library=lib", map[string]any{"id": id, "meta": map[string]any{"owner": "ann", "status": "draft"}}, &res)
+	if !maps.Equal(res.Meta, map[string]string{"owner": "ann", "status": "draft"}) {
+		t.Errorf("meta after set %v", res.Meta)
+	}
+	s.ok("POST", "/api/document/meta?library=lib", map[string]any{"id": id, "meta": map[string]any{"owner": nil, "status": "done"}}, &res)
+	if got := s.documentMeta("lib", id); !maps.Equal(got, map[string]string{"status": "done"}) {
+		t.Errorf("meta after update %v", got)
+	}
+
+	var created struct{ ID int64 }
+	s.ok("POST", "/api/document/create?library=lib", map[string]any{"title": "New", "meta": map[string]string{"owner": "bob"}}, &created)
+	if got := s.documentMeta("lib", created.ID); got["owner"] != "bob" {
+		t.Errorf("meta on create %v", got)
+	}
+
+	// Deleting a document drops its metadata
+	s.ok("POST", "/api/document/batch?library=lib", map[string]any{"operations": []map[string]any{{"op": "delete", "id": created.ID}}}, nil)
+	if ids, _ := s.listAll("lib", "meta.owner=bob"); len(ids) != 0 {
+		t.Errorf("deleted document still listed: %v", ids)
+	}
+
+	expectError(t, s.do("POST", "/api/document/meta?library=lib", map[string]any{"id": id, "meta": map[string]any{"bad key": "x"}}), http.StatusBadRequest, "META_KEY_INVALID")
+	expectError(t, s.do("POST", "/api/document/meta?library=lib", map[string]any{"id": id, "meta": map[string]any{}}), http.StatusBadRequest, "NO_CHANGES")
+	expectError(t, s.do("POST", "/api/document/meta?library=lib", map[string]any{"meta": map[string]any{"a": "b"}}), http.StatusBadRequest, "DOCUMENT_ID_REQUIRED")
+	expectError(t, s.do("POST", "/api/document/meta?library=lib", map[string]any{"id": 99, "meta": map[string]any{"a": "b"}}), http.StatusNotFound, "DOCUMENT_NOT_FOUND")
+}
+
+func TestDocumentMetaSchema(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	id := s.createDocument("lib", "Doc", "", 0)
+	s.ok("POST", "/api/document/meta?library=lib", map[string]any{"id": id, "meta": map[string]any{"legacy": "x"}}, nil)
+
+	s.ok("POST", "/api/library/config/batch?library=lib", map[string]any{"changes": []map[string]any{
+		{"name": "meta", "key": "status", "value": "string:draft,review,done"},
+		{"name": "meta", "key": "review_date", "value": "date"},
+		{"name": "meta", "key": "owner", "value": "string"},
+	}}, nil)
+	for _, spec := range []string{"color", "int:one", "date:tomorrow"} {
+		expectError(t, s.do("POST", "/api/library/config?library=lib", map[string]any{"name": "meta", "key": "x", "value": spec}), http.StatusBadRequest, "CONFIG_VALUE_INVALID")
+	}
+	expectError(t, s.do("POST", "/api/library/config?library=lib", map[string]any{"name": "meta", "key": "bad key", "value": "string"}), http.StatusBadRequest, "CONFIG_VALUE_INVALID")
+
+	var schema struct {
+		Fields []struct {
+			Key, Type string
+			Allowed   []string
+		}
+	}
+	s.ok("GET", "/api/document/meta/schema?library=lib", nil, &schema)
+	if len(schema.Fields) != 3 || schema.Fields[1].Key != "review_date" || !slices.Equal(schema.Fields[2].Allowed, []string{"draft", "review", "done"}) {
+		t.Errorf("schema %+v", schema.Fields)
+	}
+
+	s.ok("POST", "/api/document/meta?library=lib", map[string]any{"id": id, "meta": map[string]any{"status": "review", "review_date": "2026-01-31"}}, nil)
+
+	tests := []struct {
+		meta map[string]any
+		code string
+	}{
+		{map[string]any{"status": "published"}, "META_VALUE_INVALID"},
+		{map[string]any{"review_date": "soon"}, "META_VALUE_INVALID"},
+		{map[string]any{"unknown": "x"}, "META_KEY_INVALID"},
+		// One bad value rejects the whole update
+		{map[string]any{"owner": "ann", "status": "nope"}, "META_VALUE_INVALID"},
+	}
+	for _, tt := range tests {
+		env := expectError(t, s.do("POST", "/api/document/meta?library=lib", map[string]any{"id": id, "meta": tt.meta}), http.StatusBadRequest, tt.code)
+		if env.Error.Details["key"] == nil {
+			t.Errorf("%v: no key in %v", tt.meta, env.Error.Details)
+		}
+	}
+	expectError(t, s.do("POST", "/api/document/create?library=lib", map[string]any{"title": "x", "meta": map[string]string{"status": "nope"}}), http.StatusBadRequest, "META_VALUE_INVALID")
+
+	// Keys outside of the schema can still be removed
+	s.ok("POST", "/api/document/meta?library=lib", map[string]any{"id": id, "meta": map[string]any{"legacy": nil}}, nil)
+	if got := s.documentMeta("lib", id); !maps.Equal(got, map[string]string{"status": "review", "review_date": "2026-01-31"}) {
+		t.Errorf("meta %v", got)
+	}
+}
+
+func TestListDocumentsByMeta(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	a := s.createDocument("lib", "A", "", 0)
+	b := s.createDocument("lib", "B", "", 0)
+	c := s.createDocument("lib", "C", "", 0)
+	s.ok("POST", "/api/document/meta?library=lib", map[string]any{"id": a, "meta": map[string]any{"status": "draft", "owner": "ann"}}, nil)
+	s.ok("POST", "/api/document/meta?library=lib", map[string]any{"id": b, "meta": map[string]any{"status": "review", "owner": "ann"}}, nil)
+	s.ok("POST", "/api/document/meta?library=lib", map[string]any{"id": c, "meta": map[string]any{"status": "draft"}}, nil)
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"meta.status=draft", []int64{a, c}},
+		{"meta.status=draft&meta.status=review", []int64{a, b, c}},
+		{"meta.status=draft&meta.owner=ann", []int64{a}},
+		{"meta.owner=bob", nil},
+	}
+	for _, tt := range tests {
+		if ids, _ := s.listAll("lib", tt.query); !slices.Equal(ids, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.query, ids, tt.want)
+		}
+	}
+
+	var page documentPage
+	s.ok("GET", "/api/document/list?library=lib&fields=id,meta&meta.owner=ann", nil, &page)
+	if len(page.Documents) != 2 || page.Documents[0]["meta"].(map[string]any)["status"] != "draft" {
+		t.Errorf("documents %v", page.Documents)
+	}
+	expectError(t, s.do("GET", "/api/document/list?library=lib&meta.bad%20key=x", nil), http.StatusBadRequest, "META_KEY_INVALID")
+}
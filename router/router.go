@@ -2,37 +2,131 @@ package router
 
 import (
 	"database/sql"
+	"io/fs"
+	"log/slog"
+	"main/apierror"
+	"main/config"
 	"main/handlers"
+	"main/metrics"
+	"main/middleware"
+	"main/service"
+	"main/web"
+	"os"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRouter(db *sql.DB, docRoot string) *gin.Engine {
 	// db parameter is kept for backward compatibility but is no longer used
-	r := gin.Default()
+	cfg := config.Default()
+	cfg.DocRoot = docRoot
+	return New(cfg)
+}
+
+// New creates the router for the given server configuration
+func New(cfg *config.Config) *gin.Engine {
+	docRoot := cfg.DocRoot
+
+	if cfg.Log.Level == "debug" {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Logger(slog.Default()), metrics.Middleware(), gin.CustomRecovery(recoverPanic))
+
+	documents := service.NewDocumentService(docRoot)
+	libraries := service.NewLibraryService(docRoot)
+	health := service.NewHealthService(docRoot, uint64(cfg.Health.MinFreeBytes), cfg.Health.LibraryCheckInterval)
+
+	// Liveness and readiness probes, left unauthenticated for the orchestrator
+	r.GET("/healthz", handlers.Healthz())
+	r.GET("/readyz", handlers.Readyz(health))
+
+	// Prometheus metrics, protected by the same authentication as the API
+	handlers.SetMetricsLibraries(libraries)
+	r.GET("/metrics", middleware.Auth(cfg.Auth), metrics.Handler())
+
+	// API description, public so tooling can fetch it before authenticating
+	r.GET("/api/openapi.json", handlers.OpenAPISpec())
 
 	// Group all API routes under /api path
-	api := r.Group("/api")
+	api := r.Group("/api", middleware.Auth(cfg.Auth))
 	{
 		// Document endpoints
-		api.POST("/document/create", handlers.CreateDocument(docRoot))
-		api.GET("/document/tree", handlers.GetDocumentTree(docRoot))
-		api.GET("/document", handlers.GetDocumentByID(docRoot))
-		api.POST("/document/update-parent", handlers.UpdateDocumentParent(docRoot))
-		api.POST("/document/update", handlers.UpdateDocument(docRoot))
+		api.POST("/document/create", handlers.CreateDocument(documents))
+		api.GET("/document/tree", handlers.GetDocumentTree(documents))
+		api.GET("/document/list", handlers.ListDocuments(documents))
+		api.GET("/document", handlers.GetDocumentByID(documents))
+		api.POST("/document/update-parent", handlers.UpdateDocumentParent(documents))
+		api.POST("/document/update", handlers.UpdateDocument(documents))
+		api.POST("/document/batch", handlers.BatchDocuments(documents))
+		api.POST("/document/tags/add", handlers.AddDocumentTags(documents))
+		api.POST("/document/tags/remove", handlers.RemoveDocumentTags(documents))
+		api.POST("/document/meta", handlers.UpdateDocumentMeta(documents))
+		api.GET("/document/meta/schema", handlers.GetMetaSchema(documents))
+		api.GET("/document/backlinks", handlers.GetBacklinks(documents))
+		api.GET("/document/render", handlers.RenderDocument(documents))
+		api.POST("/document/stubs", handlers.CreateStubDocuments(documents))
+		api.POST("/document/transfer", handlers.TransferDocument(documents))
 
 		// Upload and image endpoints
-		api.POST("/upload/:id", handlers.UploadImage(docRoot))
+		api.POST("/upload/:id", middleware.MaxBodySize(cfg.Upload.MaxSize), handlers.UploadImage(docRoot))
 		api.GET("/pic/:library/:docid/:filename", handlers.GetImage(docRoot))
 
 		// Library endpoints
-		api.POST("/library/create", handlers.CreateLibrary())
-		api.GET("/library/list", handlers.ListLibraries(docRoot))
+		api.POST("/library/create", handlers.CreateLibrary(libraries))
+		api.GET("/library/list", handlers.ListLibraries(libraries))
+		api.POST("/library/rename", handlers.RenameLibrary(libraries))
+		api.POST("/library/archive", handlers.ArchiveLibrary(libraries))
+		api.POST("/library/delete", handlers.DeleteLibrary(libraries))
+		api.POST("/library/clone", handlers.CloneLibrary(libraries))
+		api.GET("/library/tags", handlers.GetLibraryTags(documents))
+		api.GET("/library/graph", handlers.GetLibraryGraph(documents))
+		api.GET("/library/render", handlers.RenderLibrary(documents))
 
 		// Library config endpoints
-		api.GET("/library/config", handlers.GetLibraryConfig(docRoot))
-		api.POST("/library/config", handlers.UpdateLibraryConfig(docRoot))
+		api.GET("/library/config", handlers.GetLibraryConfig(libraries))
+		api.POST("/library/config", handlers.UpdateLibraryConfig(libraries))
+		api.POST("/library/config/delete", handlers.DeleteLibraryConfig(libraries))
+		api.GET("/library/config/schema", handlers.GetConfigSchema())
+		api.POST("/library/config/batch", handlers.BatchUpdateLibraryConfig(libraries))
+		api.GET("/library/config/export", handlers.ExportLibraryConfig(libraries))
+		api.POST("/library/config/import", middleware.MaxBodySize(handlers.MaxConfigImportSize), handlers.ImportLibraryConfig(libraries))
+	}
+
+	// Serve the web admin frontend for everything outside /api
+	if frontend := frontendFS(cfg.Frontend); frontend != nil {
+		r.NoRoute(web.Handler(frontend))
+	} else {
+		r.NoRoute(func(c *gin.Context) {
+			apierror.Abort(c, middleware.GetRequestID(c), apierror.New(apierror.NotFound))
+		})
 	}
 
 	return r
 }
+
+// frontendFS returns the configured frontend files, or nil when the
+// frontend is not served by this process
+func frontendFS(cfg config.FrontendConfig) fs.FS {
+	if cfg.Dir != "" {
+		return os.DirFS(cfg.Dir)
+	}
+	if cfg.Embedded {
+		return web.Embedded()
+	}
+	return nil
+}
+
+// recoverPanic logs a recovered panic and answers with a generic error
+func recoverPanic(c *gin.Context, recovered any) {
+	slog.ErrorContext(c.Request.Context(), "panic while handling request",
+		"request_id", middleware.GetRequestID(c),
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+		"panic", recovered,
+	)
+	apierror.Abort(c, middleware.GetRequestID(c), apierror.New(apierror.Internal))
+}
@@ -0,0 +1,423 @@
+package router_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"main/config"
+	"main/router"
+	"main/store"
+)
+
+func TestMain(m *testing.M) {
+	// Request logs of the error paths would drown the test output
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// testServer is the router on a temporary document root
+type testServer struct {
+	t       *testing.T
+	root    string
+	handler http.Handler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	root := t.TempDir()
+	// Close the pooled handles before the root is removed
+	t.Cleanup(store.CloseAll)
+	return &testServer{t: t, root: root, handler: router.SetupRouter(nil, root)}
+}
+
+// do sends a request. A string body is sent as is, anything else as JSON.
+func (s *testServer) do(method, path string, body any) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return s.serve(req)
+}
+
+func (s *testServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// ok sends a request that must succeed and decodes the response into v
+func (s *testServer) ok(method, path string, body, v any) {
+	s.t.Helper()
+	rec := s.do(method, path, body)
+	if rec.Code != http.StatusOK {
+		s.t.Fatalf("%s %s: status %d, body %s", method, path, rec.Code, rec.Body)
+	}
+	if v != nil {
+		decode(s.t, rec, v)
+	}
+}
+
+// createLibrary creates a library in the document root
+func (s *testServer) createLibrary(dir string) {
+	s.t.Helper()
+	s.ok("POST", "/api/library/create", map[string]any{"name": dir}, nil)
+}
+
+// createDocument creates a document and returns its ID
+func (s *testServer) createDocument(library, title, content string, parentID int64) int64 {
+	s.t.Helper()
+	var res struct{ ID int64 }
+	s.ok("POST", "/api/document/create?library="+library, map[string]any{"title": title, "content": content, "parent_id": parentID}, &res)
+	return res.ID
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", rec.Body, err)
+	}
+}
+
+// errorEnvelope is the JSON shape of every error response
+type errorEnvelope struct {
+	Error struct {
+		Code    string         `json:"code"`
+		Message string         `json:"message"`
+		Details map[string]any `json:"details"`
+	} `json:"error"`
+	RequestID string `json:"request_id"`
+}
+
+// expectError checks the status and code of an error response and returns it
+func expectError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) errorEnvelope {
+	t.Helper()
+	var env errorEnvelope
+	decode(t, rec, &env)
+	if rec.Code != status || env.Error.Code != code {
+		t.Fatalf("got status %d code %q, want %d %q, body %s", rec.Code, env.Error.Code, status, code, rec.Body)
+	}
+	if env.Error.Message == "" || env.RequestID == "" {
+		t.Errorf("incomplete error envelope: %s", rec.Body)
+	}
+	return env
+}
+
+func TestHealth(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("secret-lib")
+
+	s.ok("GET", "/healthz", nil, nil)
+
+	rec := s.do("GET", "/readyz", nil)
+	// The disk free check depends on the machine, the library check must pass
+	var res struct {
+		Checks []struct {
+			Name string
+			OK   bool
+		}
+	}
+	decode(t, rec, &res)
+	found := false
+	for _, check := range res.Checks {
+		if check.Name == "libraries" {
+			found = true
+			if !check.OK {
+				t.Errorf("library check failed: %s", rec.Body)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no check for the libraries: %s", rec.Body)
+	}
+	// The probe is unauthenticated and must not reveal the storage layout
+	if body := rec.Body.String(); strings.Contains(body, s.root) || strings.Contains(body, "secret-lib") {
+		t.Errorf("readyz reveals paths or names: %s", body)
+	}
+}
+
+func TestReadyzCachesLibraryCheck(t *testing.T) {
+	root := t.TempDir()
+	t.Cleanup(store.CloseAll)
+	cfg := config.Default()
+	cfg.DocRoot = root
+	cfg.Health.MinFreeBytes = 0
+	h := router.New(cfg)
+	s := &testServer{t: t, root: root, handler: h}
+	s.createLibrary("lib")
+	s.ok("GET", "/readyz", nil, nil)
+
+	// A corrupt database is only noticed once the cached result expires
+	if err := os.WriteFile(filepath.Join(root, "lib", "blog.db"), []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store.CloseAll()
+	s.ok("GET", "/readyz", nil, nil)
+
+	cfg.Health.LibraryCheckInterval = 0
+	s.handler = router.New(cfg)
+	rec := s.do("GET", "/readyz", nil)
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "1 of 1 libraries failed") {
+		t.Errorf("status %d, body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestReadyzIgnoresCancelledProbe(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+
+	// A probe that gives up must not leave a failed result for the next one
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.serve(httptest.NewRequest("GET", "/readyz", nil).WithContext(ctx))
+
+	rec := s.do("GET", "/readyz", nil)
+	if strings.Contains(rec.Body.String(), "libraries failed") {
+		t.Errorf("cancelled probe was cached: %s", rec.Body)
+	}
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	s := newTestServer(t)
+	var spec struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	s.ok("GET", "/api/openapi.json", nil, &spec)
+	if spec.OpenAPI == "" || spec.Paths["/api/document/create"] == nil {
+		t.Errorf("unexpected spec: openapi %q, %d paths", spec.OpenAPI, len(spec.Paths))
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	rec := s.do("GET", "/metrics", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "go_goroutines") {
+		t.Errorf("status %d, body %.200s", rec.Code, rec.Body)
+	}
+}
+
+func TestUnknownRoute(t *testing.T) {
+	s := newTestServer(t)
+	expectError(t, s.do("GET", "/api/nothing", nil), http.StatusNotFound, "NOT_FOUND")
+}
+
+func TestErrorLanguage(t *testing.T) {
+	s := newTestServer(t)
+	req := httptest.NewRequest("GET", "/api/document/tree", nil)
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.5")
+	rec := s.serve(req)
+
+	env := expectError(t, rec, http.StatusBadRequest, "LIBRARY_REQUIRED")
+	if rec.Header().Get("Content-Language") != "zh" || env.Error.Message == "" {
+		t.Errorf("Content-Language %q, message %q", rec.Header().Get("Content-Language"), env.Error.Message)
+	}
+}
+
+// TestLibraryRequired covers every endpoint that takes the library query parameter
+func TestLibraryRequired(t *testing.T) {
+	s := newTestServer(t)
+	endpoints := []struct{ method, path string }{
+		{"POST", "/api/document/create"},
+		{"GET", "/api/document/tree"},
+		{"GET", "/api/document/list"},
+		{"GET", "/api/document?id=1"},
+		{"POST", "/api/document/update-parent"},
+		{"POST", "/api/document/update"},
+		{"POST", "/api/document/batch"},
+		{"POST", "/api/document/tags/add"},
+		{"POST", "/api/document/tags/remove"},
+		{"POST", "/api/document/meta"},
+		{"GET", "/api/document/meta/schema"},
+		{"GET", "/api/document/backlinks?id=1"},
+		{"GET", "/api/document/render?id=1"},
+		{"POST", "/api/document/stubs"},
+		{"POST", "/api/upload/1"},
+		{"POST", "/api/library/rename"},
+		{"GET", "/api/library/tags"},
+		{"GET", "/api/library/graph"},
+		{"POST", "/api/library/archive"},
+		{"POST", "/api/library/delete"},
+		{"GET", "/api/library/config"},
+		{"POST", "/api/library/config"},
+		{"POST", "/api/library/config/delete"},
+		{"POST", "/api/library/config/batch"},
+		{"GET", "/api/library/config/export"},
+		{"POST", "/api/library/config/import"},
+	}
+	for _, e := range endpoints {
+		t.Run(e.method+" "+e.path, func(t *testing.T) {
+			expectError(t, s.do(e.method, e.path, "{}"), http.StatusBadRequest, "LIBRARY_REQUIRED")
+		})
+	}
+}
+
+// TestLibraryNotFound covers unknown and invalid library names, which must
+// never create anything on disk
+func TestLibraryNotFound(t *testing.T) {
+	s := newTestServer(t)
+	for _, name := range []string{"missing", "..", "a%2Fb", "%2E%2E%2Fetc"} {
+		endpoints := []struct {
+			method, path string
+			body         any
+		}{
+			{"POST", "/api/document/create", map[string]any{"title": "x"}},
+			{"GET", "/api/document/tree", nil},
+			{"GET", "/api/document/list", nil},
+			{"GET", "/api/document?id=1", nil},
+			{"POST", "/api/document/update-parent", map[string]any{"id": 1, "parent_id": 0}},
+			{"POST", "/api/document/update", map[string]any{"id": 1, "title": "x"}},
+			{"POST", "/api/document/batch", map[string]any{"operations": []any{map[string]any{"op": "delete", "id": 1}}}},
+			{"POST", "/api/document/tags/add", map[string]any{"id": 1, "tags": []string{"x"}}},
+			{"GET", "/api/library/tags", nil},
+			{"POST", "/api/document/meta", map[string]any{"id": 1, "meta": map[string]string{"a": "b"}}},
+			{"GET", "/api/document/meta/schema", nil},
+			{"GET", "/api/document/backlinks?id=1", nil},
+			{"GET", "/api/document/render?id=1", nil},
+			{"POST", "/api/document/stubs", map[string]any{"id": 1}},
+			{"GET", "/api/library/graph", nil},
+			{"POST", "/api/library/rename", map[string]any{"name": "x"}},
+			{"POST", "/api/library/archive", map[string]any{}},
+			{"POST", "/api/library/delete", map[string]any{}},
+			{"GET", "/api/library/config", nil},
+			{"POST", "/api/library/config", map[string]any{"name": "blog", "key": "name", "value": "x"}},
+			{"POST", "/api/library/config/delete", map[string]any{"name": "x", "key": "y"}},
+			{"POST", "/api/library/config/batch", map[string]any{"changes": []any{map[string]any{"name": "x", "key": "y", "value": "z"}}}},
+			{"GET", "/api/library/config/export", nil},
+			{"POST", "/api/library/config/import", "{}"},
+		}
+		for _, e := range endpoints {
+			t.Run(name+" "+e.method+" "+e.path, func(t *testing.T) {
+				path := e.path + "?library=" + name
+				if strings.Contains(e.path, "?") {
+					path = e.path + "&library=" + name
+				}
+				expectError(t, s.do(e.method, path, e.body), http.StatusNotFound, "LIBRARY_NOT_FOUND")
+			})
+		}
+	}
+
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("document root is not empty: %v", entries)
+	}
+}
+
+// TestBadJSON covers every endpoint with a JSON body
+func TestBadJSON(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	endpoints := []string{
+		"/api/document/create?library=lib",
+		"/api/document/update-parent?library=lib",
+		"/api/document/update?library=lib",
+		"/api/document/batch?library=lib",
+		"/api/document/tags/add?library=lib",
+		"/api/document/meta?library=lib",
+		"/api/document/stubs?library=lib",
+		"/api/document/transfer",
+		"/api/library/create",
+		"/api/library/rename?library=lib",
+		"/api/library/archive?library=lib",
+		"/api/library/delete?library=lib",
+		"/api/library/clone",
+		"/api/library/config?library=lib",
+		"/api/library/config/delete?library=lib",
+		"/api/library/config/batch?library=lib",
+	}
+	for _, path := range endpoints {
+		t.Run(path, func(t *testing.T) {
+			for _, body := range []string{"{", "[]", `{"name": 1, "id": "one", "changes": {}, "operations": {}, "tags": "x", "meta": [], "archived": "yes", "token": 1}`} {
+				env := expectError(t, s.do("POST", path, body), http.StatusBadRequest, "INVALID_REQUEST")
+				if env.Error.Details["reason"] == nil {
+					t.Errorf("body %q: no reason in %v", body, env.Error.Details)
+				}
+			}
+		})
+	}
+}
+
+// TestTooLarge checks the upload body limit
+func TestTooLarge(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+
+	body := strings.Repeat("x", 33<<20)
+	req := httptest.NewRequest("POST", "/api/upload/1?library=lib", strings.NewReader(body))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	env := expectError(t, s.serve(req), http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE")
+	if env.Error.Details["limit"] == nil {
+		t.Errorf("no limit in %v", env.Error.Details)
+	}
+}
+
+func TestListCreatesDocRoot(t *testing.T) {
+	// Listing a missing document root creates it instead of failing
+	root := filepath.Join(t.TempDir(), "new")
+	t.Cleanup(store.CloseAll)
+	h := router.SetupRouter(nil, root)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/library/list", nil))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"libraries":[]}` {
+		t.Errorf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Stat(root); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFrontendCacheHeaders(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{"index.html", "app.3f9a1c2b.js", "index-BXk3kp9a.css", "my-component.js", "app.bootstrap.css", "favicon-original.png"}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := config.Default()
+	cfg.DocRoot = t.TempDir()
+	cfg.Frontend.Dir = dir
+	h := router.New(cfg)
+
+	// Only names with a real content hash are cached for good
+	want := map[string]string{
+		"index.html":           "no-cache",
+		"app.3f9a1c2b.js":      "public, max-age=31536000, immutable",
+		"index-BXk3kp9a.css":   "public, max-age=31536000, immutable",
+		"my-component.js":      "public, max-age=3600",
+		"app.bootstrap.css":    "public, max-age=3600",
+		"favicon-original.png": "public, max-age=3600",
+	}
+	for _, name := range files {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/"+name, nil))
+		if got := rec.Header().Get("Cache-Control"); rec.Code != http.StatusOK || got != want[name] {
+			t.Errorf("%s: status %d, Cache-Control %q, want %q", name, rec.Code, got, want[name])
+		}
+	}
+}
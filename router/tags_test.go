@@ -0,0 +1,139 @@
+package router_test
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func (s *testServer) libraryTags(library string) []tag {
+	s.t.Helper()
+	var res struct{ Tags []tag }
+	s.ok("GET", "/api/library/tags?library="+library, nil, &res)
+	return res.Tags
+}
+
+func (s *testServer) documentTags(library string, id int64) []string {
+	s.t.Helper()
+	var doc struct{ Tags []string }
+	s.ok("GET", "/api/document?library="+library+"&id="+itoa(id), nil, &doc)
+	return doc.Tags
+}
+
+func TestDocumentTags(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	a := s.createDocument("lib", "A", "", 0)
+	b := s.createDocument("lib", "B", "", 0)
+
+	var res struct{ Tags []string }
+	s.ok("POST", "/api/document/tags/add?library=lib", map[string]any{"id": a, "tags": []string{" Go ", "draft", "go"}}, &res)
+	if !slices.Equal(res.Tags, []string{"draft", "Go"}) {
+		t.Errorf("tags after add %v", res.Tags)
+	}
+	// Tags match case-insensitively and keep their first spelling
+	s.ok("POST", "/api/document/tags/add?library=lib", map[string]any{"id": b, "tags": []string{"GO"}}, nil)
+	if got := s.documentTags("lib", b); !slices.Equal(got, []string{"Go"}) {
+		t.Errorf("tags of B %v", got)
+	}
+
+	if got := s.libraryTags("lib"); !slices.Equal(got, []tag{{"draft", 1}, {"Go", 2}}) {
+		t.Errorf("library tags %v", got)
+	}
+
+	// Removing the last use of a tag drops it from the library
+	s.ok("POST", "/api/document/tags/remove?library=lib", map[string]any{"id": a, "tags": []string{"DRAFT", "unknown"}}, &res)
+	if !slices.Equal(res.Tags, []string{"Go"}) {
+		t.Errorf("tags after remove %v", res.Tags)
+	}
+	if got := s.libraryTags("lib"); !slices.Equal(got, []tag{{"Go", 2}}) {
+		t.Errorf("library tags %v", got)
+	}
+
+	// Deleted documents lose their tags
+	s.ok("POST", "/api/document/batch?library=lib", map[string]any{"operations": []map[string]any{{"op": "delete", "id": a}}}, nil)
+	if got := s.libraryTags("lib"); !slices.Equal(got, []tag{{"Go", 1}}) {
+		t.Errorf("library tags after delete %v", got)
+	}
+
+	// Tags can be given on create
+	var created struct{ ID int64 }
+	s.ok("POST", "/api/document/create?library=lib", map[string]any{"title": "C", "tags": []string{"new"}}, &created)
+	if got := s.documentTags("lib", created.ID); !slices.Equal(got, []string{"new"}) {
+		t.Errorf("tags of C %v", got)
+	}
+}
+
+func TestDocumentTagErrors(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	id := s.createDocument("lib", "A", "", 0)
+
+	for _, name := range []string{"", " ", "a,b", "tab\there", strings.Repeat("x", 65)} {
+		expectError(t, s.do("POST", "/api/document/tags/add?library=lib", map[string]any{"id": id, "tags": []string{name}}), http.StatusBadRequest, "INVALID_TAG")
+	}
+	expectError(t, s.do("POST", "/api/document/create?library=lib", map[string]any{"title": "x", "tags": []string{""}}), http.StatusBadRequest, "INVALID_TAG")
+	expectError(t, s.do("POST", "/api/document/tags/add?library=lib", map[string]any{"id": id, "tags": []string{}}), http.StatusBadRequest, "NO_CHANGES")
+	expectError(t, s.do("POST", "/api/document/tags/add?library=lib", map[string]any{"tags": []string{"x"}}), http.StatusBadRequest, "DOCUMENT_ID_REQUIRED")
+	expectError(t, s.do("POST", "/api/document/tags/remove?library=lib", map[string]any{"id": 99, "tags": []string{"x"}}), http.StatusNotFound, "DOCUMENT_NOT_FOUND")
+
+	// Nothing was created by the rejected requests
+	if got := s.libraryTags("lib"); len(got) != 0 {
+		t.Errorf("library tags %v", got)
+	}
+	var tree []document
+	s.ok("GET", "/api/document/tree?library=lib", nil, &tree)
+	if len(tree) != 1 {
+		t.Errorf("tree %+v", tree)
+	}
+}
+
+func TestListDocumentsByTag(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	a := s.createDocument("lib", "A", "", 0)
+	b := s.createDocument("lib", "B", "", 0)
+	s.createDocument("lib", "C", "", 0)
+	s.ok("POST", "/api/document/tags/add?library=lib", map[string]any{"id": a, "tags": []string{"go", "web"}}, nil)
+	s.ok("POST", "/api/document/tags/add?library=lib", map[string]any{"id": b, "tags": []string{"go"}}, nil)
+
+	if ids, _ := s.listAll("lib", "tag=GO&limit=1"); !slices.Equal(ids, []int64{a, b}) {
+		t.Errorf("tag=GO: %v", ids)
+	}
+	if ids, _ := s.listAll("lib", "tag=go&tag=web"); !slices.Equal(ids, []int64{a}) {
+		t.Errorf("tag=go&tag=web: %v", ids)
+	}
+	if ids, _ := s.listAll("lib", "tag=none"); len(ids) != 0 {
+		t.Errorf("tag=none: %v", ids)
+	}
+
+	var page documentPage
+	s.ok("GET", "/api/document/list?library=lib&fields=id,tags", nil, &page)
+	if len(page.Documents) != 3 || len(page.Documents[0]["tags"].([]any)) != 2 || len(page.Documents[2]["tags"].([]any)) != 0 {
+		t.Errorf("documents %v", page.Documents)
+	}
+	expectError(t, s.do("GET", "/api/document/list?library=lib&tag=a,b", nil), http.StatusBadRequest, "INVALID_TAG")
+}
+
+func TestTransferKeepsTags(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("src")
+	s.createLibrary("dst")
+	id := s.createDocument("src", "Doc", "", 0)
+	s.ok("POST", "/api/document/tags/add?library=src", map[string]any{"id": id, "tags": []string{"kept"}}, nil)
+
+	var res struct{ ID int64 }
+	s.ok("POST", "/api/document/transfer", map[string]any{"source_library": "src", "target_library": "dst", "id": id, "mode": "move"}, &res)
+	if got := s.documentTags("dst", res.ID); !slices.Equal(got, []string{"kept"}) {
+		t.Errorf("transferred tags %v", got)
+	}
+	if got := s.libraryTags("src"); len(got) != 0 {
+		t.Errorf("source library tags %v", got)
+	}
+}
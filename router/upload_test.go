@@ -0,0 +1,150 @@
+package router_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// upload posts a file to the upload endpoint under the given file name
+func (s *testServer) upload(library, docID, filename, content string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	w.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload/"+docID+"?library="+library, &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return s.serve(req)
+}
+
+func TestUploadAndGetImage(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	id := itoa(s.createDocument("lib", "Doc", "", 0))
+
+	var res struct{ Path, Filename string }
+	rec := s.upload("lib", id, "logo.png", "first")
+	decode(t, rec, &res)
+	if rec.Code != http.StatusOK || res.Filename != "logo.png" || res.Path != "/pic/lib/"+id+"/logo.png" {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+
+	get := s.do("GET", "/api"+res.Path, nil)
+	if get.Code != http.StatusOK || get.Body.String() != "first" {
+		t.Errorf("status %d, body %q", get.Code, get.Body)
+	}
+
+	expectError(t, s.do("GET", "/api/pic/lib/"+id+"/missing.png", nil), http.StatusNotFound, "IMAGE_NOT_FOUND")
+	expectError(t, s.do("GET", "/api/pic/nope/"+id+"/logo.png", nil), http.StatusNotFound, "LIBRARY_NOT_FOUND")
+}
+
+func TestUploadCollisions(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+
+	want := []string{"a.png", "a_1.png", "a_2.png"}
+	for i, name := range want {
+		var res struct{ Filename string }
+		decode(t, s.upload("lib", "1", "a.png", name), &res)
+		if res.Filename != want[i] {
+			t.Errorf("upload %d stored as %q, want %q", i, res.Filename, want[i])
+		}
+	}
+
+	// Earlier uploads are never overwritten
+	for _, name := range want {
+		data, err := os.ReadFile(filepath.Join(s.root, "lib", "pic", "1", name))
+		if err != nil || string(data) != name {
+			t.Errorf("%s: %q, %v", name, data, err)
+		}
+	}
+}
+
+func TestUploadErrors(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+
+	req := httptest.NewRequest("POST", "/api/upload/1?library=lib", nil)
+	expectError(t, s.serve(req), http.StatusBadRequest, "FILE_REQUIRED")
+
+	expectError(t, s.upload("nope", "1", "a.png", "x"), http.StatusNotFound, "LIBRARY_NOT_FOUND")
+
+	s.ok("POST", "/api/library/archive?library=lib", map[string]any{}, nil)
+	expectError(t, s.upload("lib", "1", "a.png", "x"), http.StatusForbidden, "LIBRARY_ARCHIVED")
+}
+
+func TestUploadTraversal(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+
+	// Path components in the file name are dropped
+	for _, name := range []string{"../../evil.png", `..\..\evil.png`, "/etc/evil.png"} {
+		var res struct{ Filename string }
+		rec := s.upload("lib", "1", name, "x")
+		decode(t, rec, &res)
+		if rec.Code != http.StatusOK || filepath.Base(res.Filename) != res.Filename || res.Filename == ".." {
+			t.Errorf("%q: status %d, body %s", name, rec.Code, rec.Body)
+		}
+	}
+	// Names without a usable base get a generated one
+	for _, name := range []string{"..", "."} {
+		rec := s.upload("lib", "1", name, "x")
+		if rec.Code != http.StatusOK {
+			t.Errorf("%q: status %d, body %s", name, rec.Code, rec.Body)
+		}
+	}
+
+	// The document ID must name a pic/<id> folder
+	for _, id := range []string{"..", ".", "0", "-1", "abc", "01", "%2E%2E"} {
+		expectError(t, s.upload("lib", id, "a.png", "x"), http.StatusBadRequest, "INVALID_REQUEST")
+	}
+	expectError(t, s.upload("..", "1", "a.png", "x"), http.StatusNotFound, "LIBRARY_NOT_FOUND")
+
+	// Nothing was written outside of pic/1
+	err := filepath.WalkDir(s.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(s.root, path)
+		if filepath.Dir(rel) != filepath.Join("lib", "pic", "1") && rel != filepath.Join("lib", "blog.db") {
+			t.Errorf("unexpected file %s", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetImageTraversal(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	s.upload("lib", "1", "a.png", "x")
+
+	for _, path := range []string{
+		"/api/pic/lib/%2E%2E/blog.db",
+		"/api/pic/lib/1/%2E%2E",
+		"/api/pic/lib/1/.",
+		"/api/pic/lib/abc/a.png",
+		"/api/pic/lib/01/a.png",
+	} {
+		rec := s.do("GET", path, nil)
+		if rec.Code == http.StatusOK {
+			t.Errorf("%s: served %d bytes", path, rec.Body.Len())
+		}
+	}
+	rec := s.do("GET", "/api/pic/%2E%2E/lib/blog.db", nil)
+	if rec.Code == http.StatusOK {
+		t.Errorf("library traversal served %d bytes", rec.Body.Len())
+	}
+}
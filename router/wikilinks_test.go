@@ -0,0 +1,172 @@
+package router_test
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"main/cli"
+)
+
+type wikiLink struct {
+	Target     string  `json:"target"`
+	Status     string  `json:"status"`
+	ID         int64   `json:"id"`
+	Candidates []int64 `json:"candidates"`
+}
+
+type renderedDocument struct {
+	Content   string     `json:"content"`
+	WikiLinks []wikiLink `json:"wiki_links"`
+}
+
+func TestRenderWikiLinks(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	guide := s.createDocument("lib", "User Guide", "", 0)
+	dup1 := s.createDocument("lib", "Notes", "", 0)
+	dup2 := s.createDocument("lib", "notes", "", 0)
+	id := s.createDocument("lib", "Home", "See [[user guide]], [[#"+itoa(guide)+"|the guide]] and [[User Guide]] again. [[Notes]] [[Missing]] [[#99]]", 0)
+
+	var doc renderedDocument
+	s.ok("GET", "/api/document/render?library=lib&id="+itoa(id), nil, &doc)
+	link := "(/document?id=" + itoa(guide) + ")"
+	want := "See [User Guide]" + link + ", [the guide]" + link + " and [User Guide]" + link + " again. [[Notes]] [[Missing]] [[#99]]"
+	if doc.Content != want {
+		t.Errorf("content %q, want %q", doc.Content, want)
+	}
+	wantLinks := []struct {
+		target, status string
+		id             int64
+		candidates     []int64
+	}{
+		{"user guide", "resolved", guide, nil},
+		{"#" + itoa(guide), "resolved", guide, nil},
+		{"Notes", "ambiguous", 0, []int64{dup1, dup2}},
+		{"Missing", "unresolved", 0, nil},
+		{"#99", "unresolved", 0, nil},
+	}
+	if len(doc.WikiLinks) != len(wantLinks) {
+		t.Fatalf("wiki links %+v", doc.WikiLinks)
+	}
+	for i, w := range wantLinks {
+		got := doc.WikiLinks[i]
+		if got.Target != w.target || got.Status != w.status || got.ID != w.id || !slices.Equal(got.Candidates, w.candidates) {
+			t.Errorf("wiki link %d: %+v, want %+v", i, got, w)
+		}
+	}
+
+	// Rendering does not change the stored content
+	if got := s.content("lib", id); got == doc.Content {
+		t.Errorf("stored content was resolved: %q", got)
+	}
+
+	var plain renderedDocument
+	s.ok("GET", "/api/document/render?library=lib&id="+itoa(guide), nil, &plain)
+	if plain.WikiLinks == nil || len(plain.WikiLinks) != 0 {
+		t.Errorf("wiki links of a document without any %v", plain.WikiLinks)
+	}
+	expectError(t, s.do("GET", "/api/document/render?library=lib", nil), http.StatusBadRequest, "DOCUMENT_ID_REQUIRED")
+	expectError(t, s.do("GET", "/api/document/render?library=lib&id=99", nil), http.StatusNotFound, "DOCUMENT_NOT_FOUND")
+}
+
+func TestCreateStubDocuments(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	section := s.createDocument("lib", "Section", "", 0)
+	s.createDocument("lib", "Existing", "", 0)
+	id := s.createDocument("lib", "Home", "[[Existing]] [[New Page]] [[new page|again]] [[Other]] [[#99]]", 0)
+
+	var res struct{ IDs map[string]int64 }
+	s.ok("POST", "/api/document/stubs?library=lib", map[string]any{"id": id, "parent_id": section}, &res)
+	if len(res.IDs) != 2 || res.IDs["New Page"] == 0 || res.IDs["Other"] == 0 {
+		t.Fatalf("stubs %v", res.IDs)
+	}
+	var tree []document
+	s.ok("GET", "/api/document/tree?library=lib", nil, &tree)
+	for _, doc := range tree {
+		if doc.ID == res.IDs["Other"] && doc.ParentID != section {
+			t.Errorf("stub parent %d, want %d", doc.ParentID, section)
+		}
+	}
+
+	// Now every title resolves and there is nothing left to create
+	var doc renderedDocument
+	s.ok("GET", "/api/document/render?library=lib&id="+itoa(id), nil, &doc)
+	for _, link := range doc.WikiLinks {
+		if link.Status != "resolved" && link.Target != "#99" {
+			t.Errorf("wiki link %+v", link)
+		}
+	}
+	var again struct{ IDs map[string]int64 }
+	s.ok("POST", "/api/document/stubs?library=lib", map[string]any{"id": id}, &again)
+	if len(again.IDs) != 0 {
+		t.Errorf("second run created %v", again.IDs)
+	}
+
+	expectError(t, s.do("POST", "/api/document/stubs?library=lib", map[string]any{"id": id, "parent_id": 99}), http.StatusNotFound, "PARENT_NOT_FOUND")
+	expectError(t, s.do("POST", "/api/document/stubs?library=lib", map[string]any{}), http.StatusBadRequest, "DOCUMENT_ID_REQUIRED")
+	expectError(t, s.do("POST", "/api/document/stubs?library=lib", map[string]any{"id": 99}), http.StatusNotFound, "DOCUMENT_NOT_FOUND")
+}
+
+func TestRenderLibrary(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	guide := s.createDocument("lib", "Guide", "", 0)
+	home := s.createDocument("lib", "Home", "[[Guide]] [[Missing]]", 0)
+
+	var res struct{ Documents []renderedDocument }
+	s.ok("GET", "/api/library/render?library=lib", nil, &res)
+	if len(res.Documents) != 2 {
+		t.Fatalf("documents %+v", res.Documents)
+	}
+	if got, want := res.Documents[1].Content, "[Guide](/document?id="+itoa(guide)+") [[Missing]]"; got != want {
+		t.Errorf("document %d: content %q, want %q", home, got, want)
+	}
+	if links := res.Documents[1].WikiLinks; len(links) != 2 || links[1].Status != "unresolved" {
+		t.Errorf("wiki links %+v", links)
+	}
+	expectError(t, s.do("GET", "/api/library/render", nil), http.StatusBadRequest, "LIBRARY_REQUIRED")
+}
+
+func TestWikiLinksAreTracked(t *testing.T) {
+	s := newTestServer(t)
+	s.createLibrary("lib")
+	a := s.createDocument("lib", "Alpha", "", 0)
+	b := s.createDocument("lib", "Beta", "", 0)
+	c := s.createDocument("lib", "C", "[[#"+itoa(a)+"|first]] and [[beta]] [[Missing]]", 0)
+
+	if got := s.backlinks("lib", a); !slices.Equal(got, []int64{c}) {
+		t.Errorf("backlinks of the [[#id]] target %v", got)
+	}
+	if got := s.backlinks("lib", b); !slices.Equal(got, []int64{c}) {
+		t.Errorf("backlinks of the [[Title]] target %v", got)
+	}
+
+	// Deleting the target of a [[#id]] link breaks it
+	var batch struct {
+		BrokenLinks []link `json:"broken_links"`
+	}
+	s.ok("POST", "/api/document/batch?library=lib", map[string]any{"operations": []map[string]any{{"op": "delete", "id": a}}}, &batch)
+	if !slices.Equal(batch.BrokenLinks, []link{{c, a, true}}) {
+		t.Errorf("broken links on delete %v", batch.BrokenLinks)
+	}
+	var graph struct{ Links []link }
+	s.ok("GET", "/api/library/graph?library=lib", nil, &graph)
+	if want := []link{{c, a, true}, {c, b, false}}; !slices.Equal(graph.Links, want) {
+		t.Errorf("links %v, want %v", graph.Links, want)
+	}
+	if got := s.backlinks("lib", b); !slices.Equal(got, []int64{c}) {
+		t.Errorf("backlinks after delete %v", got)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := cli.Run([]string{"fsck", "-dir", s.root, "lib"}, strings.NewReader(""), &stdout, &stderr); code == 0 {
+		t.Errorf("fsck passed: %s", stdout.String())
+	}
+	if want := "broken_link document " + itoa(c) + ": document " + itoa(a) + " does not exist"; !strings.Contains(stdout.String(), want) {
+		t.Errorf("fsck output %q, want %q", stdout.String(), want)
+	}
+}
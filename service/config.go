@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	"main/apierror"
+	"main/store"
+)
+
+// ConfigChange is a single entry of a batch config update
+type ConfigChange struct {
+	Name   string `json:"name"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Delete bool   `json:"delete"` // Remove the key instead of setting it
+}
+
+// validateConfigChanges checks every change against the schema before any is
+// applied. The index of the offending change is added to the error details.
+func validateConfigChanges(changes []ConfigChange) *apierror.Error {
+	for i, change := range changes {
+		if change.Name == "" || change.Key == "" {
+			return apierror.New(apierror.ConfigKeyRequired).With("change", i)
+		}
+		if change.Delete {
+			if field, found, _ := lookupConfigField(change.Name, change.Key); found && field.ReadOnly {
+				return configError(apierror.ConfigKeyReadOnly, change.Name, change.Key).With("change", i)
+			}
+			continue
+		}
+		if err := validateConfigValue(change.Name, change.Key, change.Value); err != nil {
+			return err.With("change", i)
+		}
+	}
+	return nil
+}
+
+func (s *libraryService) Config(ctx context.Context, library string) (map[string]map[string]string, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	config, err := store.NewConfigRepository(db).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Fill in defaults for known keys that were never set
+	applyConfigDefaults(config)
+	return config, nil
+}
+
+func (s *libraryService) ExportConfig(ctx context.Context, library string) (map[string]map[string]string, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	config, err := store.NewConfigRepository(db).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for name, keys := range config {
+		for key := range keys {
+			if field, found, _ := lookupConfigField(name, key); found && field.ReadOnly {
+				delete(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			delete(config, name)
+		}
+	}
+	return config, nil
+}
+
+func (s *libraryService) SetConfig(ctx context.Context, library, name, key, value string) (bool, error) {
+	if name == "" || key == "" {
+		return false, apierror.New(apierror.ConfigKeyRequired)
+	}
+	if err := validateConfigValue(name, key, value); err != nil {
+		return false, err
+	}
+
+	db, release, err := openLibrary(s.docRoot, library, true)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	return store.NewConfigRepository(db).Set(ctx, name, key, value)
+}
+
+func (s *libraryService) DeleteConfig(ctx context.Context, library, name, key string) (bool, error) {
+	if name == "" || key == "" {
+		return false, apierror.New(apierror.ConfigKeyRequired)
+	}
+	if field, found, _ := lookupConfigField(name, key); found && field.ReadOnly {
+		return false, configError(apierror.ConfigKeyReadOnly, name, key)
+	}
+
+	db, release, err := openLibrary(s.docRoot, library, true)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	return store.NewConfigRepository(db).Delete(ctx, name, key)
+}
+
+func (s *libraryService) ApplyConfig(ctx context.Context, library string, changes []ConfigChange, replace bool) error {
+	if err := validateConfigChanges(changes); err != nil {
+		return err
+	}
+
+	db, release, err := openLibrary(s.docRoot, library, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return store.InTx(ctx, db, func(tx *sql.Tx) error {
+		config := store.NewConfigRepository(tx)
+		if replace {
+			// Remove everything except the read-only schema keys
+			var keep [][2]string
+			for _, f := range ConfigSchema() {
+				if f.ReadOnly {
+					keep = append(keep, [2]string{f.Name, f.Key})
+				}
+			}
+			if err := config.DeleteAllExcept(ctx, keep); err != nil {
+				return err
+			}
+		}
+
+		for _, change := range changes {
+			var err error
+			if change.Delete {
+				_, err = config.Delete(ctx, change.Name, change.Key)
+			} else {
+				_, err = config.Set(ctx, change.Name, change.Key, change.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"main/apierror"
+	"main/models"
+)
+
+// configSchema lists the known library config entries. Names that have at
+// least one registered field are closed: unknown keys under them are rejected.
+// Other names remain free-form. Guarded by configSchemaMu, as fields can be
+// registered while requests read the schema.
+var (
+	configSchemaMu sync.RWMutex
+	configSchema   = []models.ConfigField{
+		{Name: "blog", Key: "name", Type: "string", Description: "Display name of the library"},
+		{Name: "blog", Key: "archived", Type: "bool", Default: "false", ReadOnly: true, Description: "Library is read-only and hidden from the default list, set through /library/archive"},
+		{Name: "blog", Key: "template", Type: "bool", Default: "false", Description: "Library can be used as a template when creating new libraries"},
+	}
+)
+
+// RegisterConfigField adds a field to the library config schema. It is safe
+// to call at any time.
+func RegisterConfigField(field models.ConfigField) {
+	configSchemaMu.Lock()
+	defer configSchemaMu.Unlock()
+	configSchema = append(configSchema, field)
+}
+
+// UnregisterConfigFields removes every field registered under name, e.g.
+// the fields a test registered
+func UnregisterConfigFields(name string) {
+	configSchemaMu.Lock()
+	defer configSchemaMu.Unlock()
+	configSchema = slices.DeleteFunc(configSchema, func(f models.ConfigField) bool { return f.Name == name })
+}
+
+// ConfigSchema returns a copy of the registered library config fields
+func ConfigSchema() []models.ConfigField {
+	configSchemaMu.RLock()
+	defer configSchemaMu.RUnlock()
+	return slices.Clone(configSchema)
+}
+
+// lookupConfigField returns the registered field for name/key.
+// closed reports whether name has registered fields at all.
+func lookupConfigField(name, key string) (field models.ConfigField, found bool, closed bool) {
+	configSchemaMu.RLock()
+	defer configSchemaMu.RUnlock()
+	for _, f := range configSchema {
+		if f.Name != name {
+			continue
+		}
+		closed = true
+		if f.Key == key {
+			return f, true, true
+		}
+	}
+	return models.ConfigField{}, false, closed
+}
+
+// validateConfigValue checks name/key/value against the schema
+func validateConfigValue(name, key, value string) *apierror.Error {
+	// The document metadata schema of the library, see parseMetaField
+	if name == metaConfigName {
+		if _, err := parseMetaField(key, value); err != nil {
+			return configError(apierror.ConfigValueInvalid, name, key).With("reason", err.Error())
+		}
+		return nil
+	}
+
+	field, found, closed := lookupConfigField(name, key)
+	if !found {
+		if closed {
+			return configError(apierror.ConfigKeyUnknown, name, key)
+		}
+		return nil
+	}
+	if field.ReadOnly {
+		return configError(apierror.ConfigKeyReadOnly, name, key)
+	}
+
+	if err := checkValue(apierror.ConfigValueInvalid, field.Type, field.Allowed, value); err != nil {
+		return err.With("name", name).With("key", key)
+	}
+	return nil
+}
+
+// checkValue checks a value against a field type and its allowed values. The
+// returned error has the given code and tells what was expected.
+func checkValue(code apierror.Code, fieldType string, allowed []string, value string) *apierror.Error {
+	var err error
+	switch fieldType {
+	case "bool":
+		if value != "true" && value != "false" {
+			err = strconv.ErrSyntax
+		}
+	case "int":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "date":
+		_, err = time.Parse(time.DateOnly, value)
+	}
+	if err != nil {
+		return apierror.New(code).With("type", fieldType)
+	}
+
+	if len(allowed) > 0 && !slices.Contains(allowed, value) {
+		return apierror.New(code).With("allowed", allowed)
+	}
+	return nil
+}
+
+// configError returns an error about the config entry name.key
+func configError(code apierror.Code, name, key string) *apierror.Error {
+	return apierror.New(code).With("name", name).With("key", key)
+}
+
+// applyConfigDefaults fills in the default value of every registered field
+// that is missing from config
+func applyConfigDefaults(config map[string]map[string]string) {
+	for _, f := range ConfigSchema() {
+		if _, ok := config[f.Name]; !ok {
+			config[f.Name] = make(map[string]string)
+		}
+		if _, ok := config[f.Name][f.Key]; !ok {
+			config[f.Name][f.Key] = f.Default
+		}
+	}
+}
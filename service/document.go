@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	"main/apierror"
+	"main/models"
+	"main/store"
+)
+
+// DocumentService manages the documents of a library
+type DocumentService interface {
+	// Create adds a document with its tags and metadata and returns its ID
+	Create(ctx context.Context, library string, doc models.Document) (int64, error)
+	// Get returns a single document with its tags and metadata
+	Get(ctx context.Context, library string, id int64) (models.Document, error)
+	// Tree returns all documents of a library, the client builds the tree
+	// from their parent IDs
+	Tree(ctx context.Context, library string) ([]models.Document, error)
+	// List returns a page of documents, see DocumentListOptions
+	List(ctx context.Context, library string, opts DocumentListOptions) (DocumentPage, error)
+	// Batch applies create, update, move and delete operations in one
+	// transaction, all or nothing
+	Batch(ctx context.Context, library string, ops []DocumentOperation) (DocumentBatchResult, error)
+	// Tags returns the tags of a library with their document counts
+	Tags(ctx context.Context, library string) ([]models.Tag, error)
+	// AddTags and RemoveTags change the tags of a document and return the
+	// tags it has afterwards
+	AddTags(ctx context.Context, library string, id int64, tags []string) ([]string, error)
+	RemoveTags(ctx context.Context, library string, id int64, tags []string) ([]string, error)
+	// MetaSchema returns the metadata fields of a library, empty if any
+	// key is accepted
+	MetaSchema(ctx context.Context, library string) ([]models.MetaField, error)
+	// SetMeta sets the metadata of a document, nil values remove their key,
+	// and returns the metadata it has afterwards
+	SetMeta(ctx context.Context, library string, id int64, meta map[string]*string) (map[string]string, error)
+	// Backlinks returns the documents whose content links to a document
+	Backlinks(ctx context.Context, library string, id int64) ([]models.DocumentRef, error)
+	// BrokenLinks returns the links of the given documents to documents
+	// that do not exist
+	BrokenLinks(ctx context.Context, library string, ids ...int64) ([]models.Link, error)
+	// Graph returns all documents of a library with the links between them
+	Graph(ctx context.Context, library string) (models.LinkGraph, error)
+	// Render returns a document with its [[Page Title]] and [[#id]] wiki
+	// links resolved to /document?id= links, and the state of every link
+	Render(ctx context.Context, library string, id int64) (models.RenderedDocument, error)
+	// RenderAll renders every document of a library like Render, reading
+	// the titles only once
+	RenderAll(ctx context.Context, library string) ([]models.RenderedDocument, error)
+	// CreateStubs creates an empty document under parentID for every wiki
+	// link title of a document that matches no document, and returns their
+	// IDs by title
+	CreateStubs(ctx context.Context, library string, id, parentID int64) (map[string]int64, error)
+	// Update changes the fields of a document that are set in update and
+	// reports whether the document was written
+	Update(ctx context.Context, library string, id int64, update DocumentUpdate) (bool, error)
+	// Move puts a document under another parent, 0 for the root
+	Move(ctx context.Context, library string, id, parentID int64) error
+	// Transfer copies a document subtree from source to target under
+	// parentID, 0 for the root, and returns the new IDs by old ID. With
+	// move set, the documents are removed from source afterwards.
+	Transfer(ctx context.Context, source, target string, id, parentID int64, move bool) (map[int64]int64, error)
+}
+
+// DocumentUpdate holds the fields to change, nil fields are left as they are
+type DocumentUpdate struct {
+	Title   *string
+	Content *string
+}
+
+type documentService struct {
+	docRoot string
+}
+
+// NewDocumentService returns a DocumentService on the libraries under docRoot
+func NewDocumentService(docRoot string) DocumentService {
+	return &documentService{docRoot: docRoot}
+}
+
+func (s *documentService) Create(ctx context.Context, library string, doc models.Document) (int64, error) {
+	tags, err := normalizeTags(doc.Tags)
+	if err != nil {
+		return 0, err
+	}
+	db, release, err := openLibrary(s.docRoot, library, true)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	var id int64
+	err = store.InTx(ctx, db, func(tx *sql.Tx) error {
+		var err error
+		if id, err = store.NewDocumentRepository(tx).Create(ctx, doc); err != nil {
+			return err
+		}
+		meta := make(map[string]*string, len(doc.Meta))
+		for key, value := range doc.Meta {
+			meta[key] = &value
+		}
+		if err := setMeta(ctx, tx, id, meta); err != nil {
+			return err
+		}
+		return store.NewTagRepository(tx).Add(ctx, id, tags)
+	})
+	return id, err
+}
+
+func (s *documentService) Get(ctx context.Context, library string, id int64) (models.Document, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return models.Document{}, err
+	}
+	defer release()
+
+	doc, err := store.NewDocumentRepository(db).Get(ctx, id)
+	if err == store.ErrNotFound {
+		return doc, apierror.New(apierror.DocumentNotFound)
+	}
+	if err != nil {
+		return doc, err
+	}
+	if doc.Tags, err = store.NewTagRepository(db).ForDocument(ctx, id); err != nil {
+		return doc, err
+	}
+	doc.Meta, err = store.NewMetaRepository(db).ForDocument(ctx, id)
+	return doc, err
+}
+
+func (s *documentService) Tree(ctx context.Context, library string) ([]models.Document, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return store.NewDocumentRepository(db).All(ctx)
+}
+
+func (s *documentService) Update(ctx context.Context, library string, id int64, update DocumentUpdate) (bool, error) {
+	db, release, err := openLibrary(s.docRoot, library, true)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	if id <= 0 {
+		return false, apierror.New(apierror.DocumentIDRequired)
+	}
+	docs := store.NewDocumentRepository(db)
+	exists, err := docs.Exists(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, apierror.New(apierror.DocumentNotFound)
+	}
+	if update.Title == nil && update.Content == nil {
+		return false, apierror.New(apierror.NoChanges)
+	}
+	return docs.Update(ctx, id, update.Title, update.Content)
+}
+
+func (s *documentService) Move(ctx context.Context, library string, id, parentID int64) error {
+	db, release, err := openLibrary(s.docRoot, library, true)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// 防止将节点拖动到自己或其子节点下（避免递归死循环结构）
+	docs := store.NewDocumentRepository(db)
+	cycle, err := isDescendant(ctx, docs, parentID, id)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return apierror.New(apierror.CycleDetected).With("id", id).With("parent_id", parentID)
+	}
+	return docs.SetParent(ctx, id, parentID)
+}
+
+// isDescendant reports whether id is ancestor itself or lies below it, by
+// walking up the parent chain of id. The walk stops at existing cycles.
+func isDescendant(ctx context.Context, docs *store.DocumentRepository, id, ancestor int64) (bool, error) {
+	visited := make(map[int64]bool)
+	for id != 0 && !visited[id] {
+		if id == ancestor {
+			return true, nil
+		}
+		visited[id] = true
+		parentID, err := docs.ParentID(ctx, id)
+		if err == store.ErrNotFound {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		id = parentID
+	}
+	return false, nil
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"main/apierror"
+	"main/models"
+	"main/store"
+)
+
+// DocumentOperation is a single entry of a batch. Documents created earlier in
+// the same batch are referred to by their temp_id instead of an ID.
+type DocumentOperation struct {
+	Op string `json:"op"` // create, update, move or delete
+	// Target of update, move and delete
+	ID     int64  `json:"id"`
+	TempID string `json:"temp_id"` // Names the new document of a create
+	// Parent of create and move, 0 for the root
+	ParentID     int64  `json:"parent_id"`
+	ParentTempID string `json:"parent_temp_id"`
+	// Fields of create and update, nil or an empty title leave them unchanged
+	Title   *string `json:"title"`
+	Content *string `json:"content"`
+}
+
+// DocumentBatchResult maps the temp_id of every created document to its ID
+type DocumentBatchResult struct {
+	IDs     map[string]int64 `json:"ids"`
+	Deleted []int64          `json:"deleted"` // Including descendants
+	// Links of written documents and links to deleted documents that point
+	// nowhere after the batch
+	BrokenLinks []models.Link `json:"broken_links"`
+	written     []int64
+}
+
+func (s *documentService) Batch(ctx context.Context, library string, ops []DocumentOperation) (DocumentBatchResult, error) {
+	result := DocumentBatchResult{IDs: map[string]int64{}, Deleted: []int64{}}
+	db, release, err := openLibrary(s.docRoot, library, true)
+	if err != nil {
+		return result, err
+	}
+	defer release()
+
+	err = store.InTx(ctx, db, func(tx *sql.Tx) error {
+		docs := store.NewDocumentRepository(tx)
+		for i, op := range ops {
+			if err := applyDocumentOperation(ctx, docs, op, &result); err != nil {
+				if e, ok := err.(*apierror.Error); ok {
+					e.With("operation", i)
+				}
+				return err
+			}
+		}
+		broken, err := store.NewLinkRepository(tx).Broken(ctx)
+		if err != nil {
+			return err
+		}
+		result.BrokenLinks = slices.DeleteFunc(broken, func(link models.Link) bool {
+			return !slices.Contains(result.written, link.Source) && !slices.Contains(result.Deleted, link.Target)
+		})
+		return nil
+	})
+	if err != nil {
+		return DocumentBatchResult{}, err
+	}
+
+	// Images of deleted documents, after the commit so a failed batch keeps them
+	for _, id := range result.Deleted {
+		os.RemoveAll(filepath.Join(s.docRoot, library, "pic", strconv.FormatInt(id, 10)))
+	}
+	return result, nil
+}
+
+// applyDocumentOperation applies one operation of a batch inside its transaction
+func applyDocumentOperation(ctx context.Context, docs *store.DocumentRepository, op DocumentOperation, result *DocumentBatchResult) error {
+	switch op.Op {
+	case "create", "update", "move", "delete":
+	default:
+		return invalidRequest("op must be create, update, move or delete")
+	}
+
+	if op.Op == "create" {
+		if _, taken := result.IDs[op.TempID]; taken && op.TempID != "" {
+			return invalidRequest("temp_id " + op.TempID + " is used twice")
+		}
+		parentID, err := batchParent(ctx, docs, op, result)
+		if err != nil {
+			return err
+		}
+		doc := models.Document{ParentID: parentID}
+		if op.Title != nil {
+			doc.Title = *op.Title
+		}
+		if op.Content != nil {
+			doc.Content = *op.Content
+		}
+		id, err := docs.Create(ctx, doc)
+		if err != nil {
+			return err
+		}
+		if op.TempID != "" {
+			result.IDs[op.TempID] = id
+		}
+		result.written = append(result.written, id)
+		return nil
+	}
+
+	id, err := batchTarget(ctx, docs, op, result)
+	if err != nil {
+		return err
+	}
+	switch op.Op {
+	case "update":
+		// Same rule as the update endpoint, an empty title is no change
+		if op.Title != nil && *op.Title == "" {
+			op.Title = nil
+		}
+		if op.Title == nil && op.Content == nil {
+			return apierror.New(apierror.NoChanges)
+		}
+		result.written = append(result.written, id)
+		_, err := docs.Update(ctx, id, op.Title, op.Content)
+		return err
+
+	case "move":
+		parentID, err := batchParent(ctx, docs, op, result)
+		if err != nil {
+			return err
+		}
+		cycle, err := isDescendant(ctx, docs, parentID, id)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return apierror.New(apierror.CycleDetected).With("id", id).With("parent_id", parentID)
+		}
+		return docs.SetParent(ctx, id, parentID)
+
+	case "delete":
+		ids, err := docs.SubtreeIDs(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := docs.Delete(ctx, id); err != nil {
+				return err
+			}
+		}
+		result.Deleted = append(result.Deleted, ids...)
+	}
+	return nil
+}
+
+// batchTarget resolves the existing document an operation applies to
+func batchTarget(ctx context.Context, docs *store.DocumentRepository, op DocumentOperation, result *DocumentBatchResult) (int64, error) {
+	id := op.ID
+	if op.TempID != "" {
+		if op.ID != 0 {
+			return 0, invalidRequest("id and temp_id are mutually exclusive")
+		}
+		var found bool
+		if id, found = result.IDs[op.TempID]; !found {
+			return 0, invalidRequest("temp_id " + op.TempID + " is not created by an earlier operation")
+		}
+	}
+	if id <= 0 {
+		return 0, apierror.New(apierror.DocumentIDRequired)
+	}
+	exists, err := docs.Exists(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, apierror.New(apierror.DocumentNotFound).With("id", id)
+	}
+	return id, nil
+}
+
+// batchParent resolves the parent of a create or move, which must exist
+func batchParent(ctx context.Context, docs *store.DocumentRepository, op DocumentOperation, result *DocumentBatchResult) (int64, error) {
+	parentID := op.ParentID
+	if op.ParentTempID != "" {
+		if op.ParentID != 0 {
+			return 0, invalidRequest("parent_id and parent_temp_id are mutually exclusive")
+		}
+		var found bool
+		if parentID, found = result.IDs[op.ParentTempID]; !found {
+			return 0, invalidRequest("parent_temp_id " + op.ParentTempID + " is not created by an earlier operation")
+		}
+	}
+	if parentID == 0 {
+		return 0, nil
+	}
+	exists, err := docs.Exists(ctx, parentID)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, apierror.New(apierror.ParentNotFound).With("parent_id", parentID)
+	}
+	return parentID, nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"main/apierror"
+	"main/store"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// DocumentListOptions selects a page of documents. Zero values mean no filter.
+type DocumentListOptions struct {
+	Sort   string // id (default), title or updated_at
+	Desc   bool
+	Limit  int    // Defaults to 50, at most 500
+	Cursor string // NextCursor of the previous page
+	// Fields of the documents to return, all when empty
+	Fields        []string
+	ParentID      *int64
+	TitlePrefix   string
+	UpdatedAfter  time.Time // Inclusive
+	UpdatedBefore time.Time // Exclusive
+	Tags          []string  // Only documents with all of these tags
+	// Only documents with one of the values of every metadata key
+	Meta map[string][]string
+}
+
+// documentFields are the fields List can return, the columns of the
+// documents table, the tags and the metadata
+var documentFields = append(slices.Clone(store.DocumentColumns), "tags", "meta")
+
+// DocumentPage is a page of documents with only the requested fields
+type DocumentPage struct {
+	Documents []map[string]any `json:"documents"`
+	// NextCursor continues the listing, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// listCursor is the position after the last document of a page. The sort is
+// included so a cursor cannot be used with another order.
+type listCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	store.DocumentKey
+}
+
+func (s *documentService) List(ctx context.Context, library string, opts DocumentListOptions) (DocumentPage, error) {
+	query, err := documentQuery(opts)
+	if err != nil {
+		return DocumentPage{}, err
+	}
+
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return DocumentPage{}, err
+	}
+	defer release()
+
+	// Tags and metadata are not columns and are read separately
+	fields := query.Columns
+	query.Columns = slices.DeleteFunc(slices.Clone(fields), func(f string) bool { return f == "tags" || f == "meta" })
+	if len(query.Columns) == 0 {
+		query.Columns = []string{"id"}
+	}
+
+	// One extra document tells whether there is a next page
+	limit := query.Limit
+	query.Limit++
+	docs, err := store.NewDocumentRepository(db).List(ctx, query)
+	if err != nil {
+		return DocumentPage{}, err
+	}
+
+	page := DocumentPage{Documents: []map[string]any{}}
+	if len(docs) > limit {
+		docs = docs[:limit]
+		cursor, _ := json.Marshal(listCursor{Sort: query.Sort, Desc: query.Desc, DocumentKey: query.Key(docs[limit-1])})
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(cursor)
+	}
+
+	ids := make([]int64, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	var tags map[int64][]string
+	if slices.Contains(fields, "tags") {
+		if tags, err = store.NewTagRepository(db).ForDocuments(ctx, ids); err != nil {
+			return DocumentPage{}, err
+		}
+	}
+	var meta map[int64]map[string]string
+	if slices.Contains(fields, "meta") {
+		if meta, err = store.NewMetaRepository(db).ForDocuments(ctx, ids); err != nil {
+			return DocumentPage{}, err
+		}
+	}
+	for _, doc := range docs {
+		docMeta := meta[doc.ID]
+		if docMeta == nil {
+			docMeta = map[string]string{}
+		}
+		all := map[string]any{
+			"tags":       append([]string{}, tags[doc.ID]...),
+			"meta":       docMeta,
+			"id":         doc.ID,
+			"title":      doc.Title,
+			"content":    doc.Content,
+			"parent_id":  doc.ParentID,
+			"created_at": doc.CreatedAt,
+			"updated_at": doc.UpdatedAt,
+		}
+		projected := make(map[string]any, len(fields))
+		for _, field := range fields {
+			projected[field] = all[field]
+		}
+		page.Documents = append(page.Documents, projected)
+	}
+	return page, nil
+}
+
+// documentQuery validates the list options
+func documentQuery(opts DocumentListOptions) (store.DocumentQuery, error) {
+	query := store.DocumentQuery{
+		Sort:        opts.Sort,
+		Desc:        opts.Desc,
+		Limit:       opts.Limit,
+		Columns:     opts.Fields,
+		ParentID:    opts.ParentID,
+		TitlePrefix: opts.TitlePrefix,
+	}
+	if query.Sort == "" {
+		query.Sort = "id"
+	}
+	if !slices.Contains(store.DocumentSorts, query.Sort) {
+		return query, invalidRequest("sort must be one of " + strings.Join(store.DocumentSorts, ", "))
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultListLimit
+	}
+	if query.Limit < 1 || query.Limit > maxListLimit {
+		return query, invalidRequest("limit must be between 1 and 500")
+	}
+	if len(query.Columns) == 0 {
+		query.Columns = documentFields
+	}
+	for _, field := range query.Columns {
+		if !slices.Contains(documentFields, field) {
+			return query, invalidRequest("unknown field " + field + ", fields are " + strings.Join(documentFields, ", "))
+		}
+	}
+	tags, err := normalizeTags(opts.Tags)
+	if err != nil {
+		return query, err
+	}
+	query.Tags = tags
+	for key, values := range opts.Meta {
+		if !metaKeyPattern.MatchString(key) {
+			return query, apierror.New(apierror.MetaKeyInvalid).With("key", key)
+		}
+		if len(values) == 0 {
+			return query, invalidRequest("meta." + key + " needs a value")
+		}
+	}
+	query.Meta = opts.Meta
+	if !opts.UpdatedAfter.IsZero() {
+		query.UpdatedAfter = store.Timestamp(opts.UpdatedAfter)
+	}
+	if !opts.UpdatedBefore.IsZero() {
+		query.UpdatedBefore = store.Timestamp(opts.UpdatedBefore)
+	}
+
+	if opts.Cursor != "" {
+		var cursor listCursor
+		data, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+		if err == nil {
+			err = json.Unmarshal(data, &cursor)
+		}
+		if err != nil {
+			return query, invalidRequest("invalid cursor")
+		}
+		if cursor.Sort != query.Sort || cursor.Desc != query.Desc {
+			return query, invalidRequest("cursor was returned for another sort order")
+		}
+		query.After = &cursor.DocumentKey
+	}
+	return query, nil
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+
+	"main/store"
+)
+
+// Kinds of problems reported by LibraryService.Check
+const (
+	FsckIntegrity       = "integrity"         // Reported by PRAGMA integrity_check
+	FsckOrphan          = "orphan"            // parent_id points to a missing document
+	FsckSelfParent      = "self_parent"       // parent_id is the document itself
+	FsckCycle           = "cycle"             // Documents are each other's ancestors
+	FsckStaleImages     = "stale_images"      // pic/<docid> folder of a missing document
+	FsckMissingImage    = "missing_image"     // Content references an image that does not exist
+	FsckUnusedImage     = "unused_image"      // Image that no content references
+	FsckInvalidImageDir = "invalid_image_dir" // Entry in pic that is not a document folder
+	FsckBrokenLink      = "broken_link"       // Content links to a missing document
+)
+
+// FsckIssue is a single problem found in a library
+type FsckIssue struct {
+	Kind       string
+	DocumentID int64  // Affected document, 0 if none
+	Path       string // Affected file relative to the library, if any
+	Detail     string
+	Repaired   bool
+}
+
+func (i FsckIssue) String() string {
+	s := i.Kind
+	if i.DocumentID != 0 {
+		s += fmt.Sprintf(" document %d", i.DocumentID)
+	}
+	if i.Path != "" {
+		s += " " + i.Path
+	}
+	if i.Detail != "" {
+		s += ": " + i.Detail
+	}
+	if i.Repaired {
+		s += " (repaired)"
+	}
+	return s
+}
+
+// imageRefPattern matches image URLs as returned by UploadImage, with or
+// without the /api prefix the router serves them under
+var imageRefPattern = regexp.MustCompile(`/pic/([^/\s"'()]+)/(\d+)/([^/\s"'()?#<>\]]+)`)
+
+// fsckDocument is the part of a document the checks need
+type fsckDocument struct {
+	parentID int64
+	content  string
+}
+
+// Check checks a library for database corruption, a broken document tree
+// and images that do not match the documents. With repair set, orphans,
+// self-parents and one document of every cycle are moved to the root, which
+// makes every document reachable again. Image problems are only reported, as
+// deleting files cannot be undone.
+func (s *libraryService) Check(ctx context.Context, library string, repair bool) ([]FsckIssue, error) {
+	problems, err := s.IntegrityCheck(ctx, library)
+	if err != nil {
+		return nil, err
+	}
+	var issues []FsckIssue
+	for _, problem := range problems {
+		issues = append(issues, FsckIssue{Kind: FsckIntegrity, Detail: problem})
+	}
+	// Writing to a corrupt database can make things worse
+	repair = repair && len(problems) == 0
+
+	db, release, err := openLibrary(s.docRoot, library, repair)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	outline, err := store.NewDocumentRepository(db).Outline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[int64]fsckDocument, len(outline))
+	for _, doc := range outline {
+		docs[doc.ID] = fsckDocument{parentID: doc.ParentID, content: doc.Content}
+	}
+
+	treeIssues := checkTree(docs)
+	if repair && len(treeIssues) > 0 {
+		if err := reparentToRoot(ctx, db, treeIssues); err != nil {
+			return nil, err
+		}
+		for i := range treeIssues {
+			treeIssues[i].Repaired = true
+		}
+	}
+	issues = append(issues, treeIssues...)
+	issues = append(issues, checkLinks(docs)...)
+
+	imageIssues, err := checkImages(filepath.Join(s.docRoot, library), library, docs)
+	if err != nil {
+		return nil, err
+	}
+	return append(issues, imageIssues...), nil
+}
+
+// checkTree finds orphans, self-parents and cycles. Each cycle is reported
+// once, on its document with the lowest ID.
+func checkTree(docs map[int64]fsckDocument) []FsckIssue {
+	ids := make([]int64, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var issues []FsckIssue
+	// 1 while on the current walk, 2 once known to reach the root or a problem
+	state := make(map[int64]int)
+	for _, start := range ids {
+		var walk []int64
+		id := start
+		for state[id] == 0 {
+			state[id] = 1
+			walk = append(walk, id)
+
+			parent := docs[id].parentID
+			if parent == 0 {
+				break
+			}
+			if parent == id {
+				issues = append(issues, FsckIssue{Kind: FsckSelfParent, DocumentID: id})
+				break
+			}
+			if _, ok := docs[parent]; !ok {
+				issues = append(issues, FsckIssue{Kind: FsckOrphan, DocumentID: id, Detail: fmt.Sprintf("parent %d does not exist", parent)})
+				break
+			}
+			if state[parent] == 1 {
+				issues = append(issues, cycleIssue(walk, parent))
+				break
+			}
+			id = parent
+		}
+		for _, id := range walk {
+			state[id] = 2
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].DocumentID < issues[j].DocumentID })
+	return issues
+}
+
+// checkLinks finds document and [[#id]] links in content whose target does
+// not exist
+func checkLinks(docs map[int64]fsckDocument) []FsckIssue {
+	var issues []FsckIssue
+	for id, doc := range docs {
+		targets := store.ParseLinks(doc.content)
+		wikiIDs, _ := store.ParseWikiLinks(doc.content)
+		targets = append(targets, wikiIDs...)
+		slices.Sort(targets)
+		for _, target := range slices.Compact(targets) {
+			if _, ok := docs[target]; !ok {
+				issues = append(issues, FsckIssue{Kind: FsckBrokenLink, DocumentID: id, Detail: fmt.Sprintf("document %d does not exist", target)})
+			}
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].DocumentID < issues[j].DocumentID })
+	return issues
+}
+
+// cycleIssue describes the cycle at the end of walk that starts at first
+func cycleIssue(walk []int64, first int64) FsckIssue {
+	i := len(walk) - 1
+	for walk[i] != first {
+		i--
+	}
+	cycle := walk[i:]
+	lowest := cycle[0]
+	for _, id := range cycle {
+		lowest = min(lowest, id)
+	}
+	detail := "through"
+	for _, id := range cycle {
+		detail += " " + strconv.FormatInt(id, 10)
+	}
+	return FsckIssue{Kind: FsckCycle, DocumentID: lowest, Detail: detail}
+}
+
+// reparentToRoot moves the documents of the tree issues to the root in one
+// transaction
+func reparentToRoot(ctx context.Context, db *sql.DB, issues []FsckIssue) error {
+	return store.InTx(ctx, db, func(tx *sql.Tx) error {
+		docs := store.NewDocumentRepository(tx)
+		for _, issue := range issues {
+			if err := docs.SetParent(ctx, issue.DocumentID, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// checkImages compares the pic folder of a library with its documents and
+// the image URLs in their content
+func checkImages(libPath, name string, docs map[int64]fsckDocument) ([]FsckIssue, error) {
+	// Files referenced by content, by pic relative path
+	referenced := make(map[string]bool)
+	var issues []FsckIssue
+
+	ids := make([]int64, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		for _, m := range imageRefPattern.FindAllStringSubmatch(docs[id].content, -1) {
+			if m[1] != name {
+				continue // Image of another library
+			}
+			rel := filepath.Join("pic", m[2], m[3])
+			if referenced[rel] {
+				continue
+			}
+			referenced[rel] = true
+			if _, err := os.Stat(filepath.Join(libPath, rel)); os.IsNotExist(err) {
+				issues = append(issues, FsckIssue{Kind: FsckMissingImage, DocumentID: id, Path: filepath.ToSlash(rel)})
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(libPath, "pic"))
+	if os.IsNotExist(err) {
+		return issues, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		rel := filepath.Join("pic", entry.Name())
+		docID, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if !entry.IsDir() || err != nil {
+			issues = append(issues, FsckIssue{Kind: FsckInvalidImageDir, Path: filepath.ToSlash(rel)})
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(libPath, rel))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := docs[docID]; !ok {
+			issues = append(issues, FsckIssue{Kind: FsckStaleImages, DocumentID: docID, Path: filepath.ToSlash(rel), Detail: fmt.Sprintf("%d file(s) left", len(files))})
+			continue
+		}
+		for _, file := range files {
+			fileRel := filepath.Join(rel, file.Name())
+			if !referenced[fileRel] {
+				issues = append(issues, FsckIssue{Kind: FsckUnusedImage, DocumentID: docID, Path: filepath.ToSlash(fileRel)})
+			}
+		}
+	}
+	return issues, nil
+}
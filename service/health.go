@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"main/store"
+)
+
+// HealthCheck is the result of a single readiness check. It is reported by
+// an unauthenticated endpoint, so it holds no paths or library names.
+type HealthCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// HealthService checks whether the server can do its work
+type HealthService interface {
+	// Ready checks that the document root is readable and writable, that
+	// every library passes PRAGMA quick_check and that enough disk space is
+	// free. The causes of failures are logged.
+	Ready(ctx context.Context) []HealthCheck
+}
+
+type healthService struct {
+	docRoot      string
+	minFreeBytes uint64
+	libraries    LibraryService
+	// interval is how long the library check result is reused, so frequent
+	// probes do not scan every database
+	interval time.Duration
+
+	mu      sync.Mutex
+	checked time.Time
+	last    HealthCheck
+}
+
+// NewHealthService returns a HealthService on the libraries under docRoot
+func NewHealthService(docRoot string, minFreeBytes uint64, libraryInterval time.Duration) HealthService {
+	return &healthService{
+		docRoot:      docRoot,
+		minFreeBytes: minFreeBytes,
+		libraries:    NewLibraryService(docRoot),
+		interval:     libraryInterval,
+	}
+}
+
+func (s *healthService) Ready(ctx context.Context) []HealthCheck {
+	return []HealthCheck{s.checkDocRoot(), s.checkDiskFree(), s.checkLibraries(ctx)}
+}
+
+// checkDocRoot lists the document root and writes and removes a probe file in it
+func (s *healthService) checkDocRoot() HealthCheck {
+	check := HealthCheck{Name: "doc_root"}
+	if _, err := os.ReadDir(s.docRoot); err != nil {
+		slog.Warn("document root is not readable", "error", err)
+		check.Error = "not readable"
+		return check
+	}
+
+	probe, err := os.CreateTemp(s.docRoot, ".readyz-*")
+	if err != nil {
+		slog.Warn("document root is not writable", "error", err)
+		check.Error = "not writable"
+		return check
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	check.OK = true
+	return check
+}
+
+// checkDiskFree compares the free space of the document root's filesystem with the threshold
+func (s *healthService) checkDiskFree() HealthCheck {
+	check := HealthCheck{Name: "disk_free"}
+	free, supported, err := diskFree(s.docRoot)
+	switch {
+	case !supported:
+		check.OK = true
+		check.Detail = "not supported on this platform"
+	case err != nil:
+		slog.Warn("cannot read free disk space", "error", err)
+		check.Error = "cannot read free disk space"
+	case free < s.minFreeBytes:
+		check.Error = fmt.Sprintf("%d bytes free, below the %d byte threshold", free, s.minFreeBytes)
+	default:
+		check.OK = true
+		check.Detail = fmt.Sprintf("%d bytes free", free)
+	}
+	return check
+}
+
+// libraryCheckTimeout bounds a single run of the library check
+const libraryCheckTimeout = time.Minute
+
+// checkLibraries returns the last library check, running it again once it
+// is older than the interval. Concurrent probes wait for a single run.
+func (s *healthService) checkLibraries(ctx context.Context) HealthCheck {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.checked.IsZero() || time.Since(s.checked) >= s.interval {
+		// The result is shared with later probes, so it must not fail just
+		// because the probe that started the run went away
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), libraryCheckTimeout)
+		defer cancel()
+		s.last = s.quickCheckLibraries(ctx)
+		s.checked = time.Now()
+	}
+	return s.last
+}
+
+// quickCheckLibraries runs PRAGMA quick_check on every library and reports
+// how many failed. The failed libraries are logged.
+func (s *healthService) quickCheckLibraries(ctx context.Context) HealthCheck {
+	check := HealthCheck{Name: "libraries"}
+	dirs, err := s.libraries.Dirs()
+	if err != nil {
+		// The cause is reported by checkDocRoot
+		check.Error = "cannot list libraries"
+		return check
+	}
+
+	start := time.Now()
+	failed := 0
+	for _, name := range dirs {
+		if err := s.quickCheck(ctx, name); err != nil {
+			slog.Warn("library failed the readiness check", "library", name, "error", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		check.Error = fmt.Sprintf("%d of %d libraries failed quick_check", failed, len(dirs))
+		return check
+	}
+
+	check.OK = true
+	check.Detail = fmt.Sprintf("%d libraries passed quick_check in %s", len(dirs), time.Since(start).Round(time.Millisecond))
+	return check
+}
+
+// quickCheck opens one library through the pool and runs PRAGMA quick_check
+func (s *healthService) quickCheck(ctx context.Context, name string) error {
+	db, release, err := store.Acquire(s.docRoot, name, false)
+	if err == store.ErrLibraryUnavailable {
+		// Being renamed or deleted right now, not a storage problem
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open failed: %w", err)
+	}
+	defer release()
+
+	problems, err := store.Check(ctx, db, true)
+	if err != nil {
+		return fmt.Errorf("quick_check failed: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("quick_check reported %d problem(s): %v", len(problems), problems)
+	}
+	return nil
+}
@@ -0,0 +1,480 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"main/apierror"
+	"main/models"
+	"main/store"
+)
+
+// LibraryService manages the libraries under the document root and their config
+type LibraryService interface {
+	// Create creates an empty library at path, or a copy of the template
+	// library when template is set
+	Create(ctx context.Context, name, path, template string) (models.Library, error)
+	// Clone copies the documents, config and images of source into the new
+	// library directory dir. name defaults to dir.
+	Clone(ctx context.Context, source, dir, name string) (models.Library, error)
+	// List returns the libraries, archived ones only when includeArchived is set
+	List(ctx context.Context, includeArchived bool) ([]models.Library, error)
+	// Rename changes the directory and/or the display name of a library.
+	// Empty values are left unchanged.
+	Rename(ctx context.Context, library, dir, name string) (models.Library, error)
+	// Archive makes a library read-only and hidden, or restores it
+	Archive(ctx context.Context, library string, archived bool) error
+	// Delete removes a library and all of its files
+	Delete(ctx context.Context, library string) error
+	// Exists reports whether library names a library under the document root
+	Exists(library string) bool
+	// Dirs returns the directory names of all libraries, archived ones
+	// included, in sorted order
+	Dirs() ([]string, error)
+
+	// Migrate applies pending schema migrations to a library and returns
+	// the schema version before and after
+	Migrate(ctx context.Context, library string) (from, to int, err error)
+	// IntegrityCheck runs PRAGMA integrity_check on a library and returns
+	// the problems it reports, none when the database is intact
+	IntegrityCheck(ctx context.Context, library string) ([]string, error)
+	// Check looks for corruption, a broken document tree, broken links and
+	// images that do not match the documents. With repair set, the tree is
+	// repaired where possible.
+	Check(ctx context.Context, library string, repair bool) ([]FsckIssue, error)
+	// Backup writes a gzipped tar archive of a library to w
+	Backup(ctx context.Context, library string, w io.Writer) error
+	// Stats returns the number of documents and the image storage of a library
+	Stats(ctx context.Context, library string) (LibraryStats, error)
+
+	// Config returns the config of a library with defaults for unset fields
+	Config(ctx context.Context, library string) (map[string]map[string]string, error)
+	// ExportConfig returns the config without read-only keys, so it can be
+	// imported into another library as is
+	ExportConfig(ctx context.Context, library string) (map[string]map[string]string, error)
+	// SetConfig validates and stores a single value and reports whether it was written
+	SetConfig(ctx context.Context, library, name, key, value string) (bool, error)
+	// DeleteConfig removes a value and reports whether it existed. Known keys
+	// fall back to their schema default afterwards.
+	DeleteConfig(ctx context.Context, library, name, key string) (bool, error)
+	// ApplyConfig validates and applies many changes atomically. With replace
+	// set, every other key that is not read-only is removed.
+	ApplyConfig(ctx context.Context, library string, changes []ConfigChange, replace bool) error
+}
+
+type libraryService struct {
+	docRoot string
+}
+
+// NewLibraryService returns a LibraryService on the libraries under docRoot
+func NewLibraryService(docRoot string) LibraryService {
+	return &libraryService{docRoot: docRoot}
+}
+
+// libraryNotFound returns the error for a library that does not exist
+func libraryNotFound(name string) *apierror.Error {
+	return apierror.New(apierror.LibraryNotFound).With("library", name)
+}
+
+func (s *libraryService) Create(ctx context.Context, name, path, template string) (models.Library, error) {
+	library := models.Library{Name: name, Path: path}
+	if path == "" {
+		// Without a path the library is created under the document root,
+		// with the name as its directory
+		if name == "" {
+			return models.Library{}, apierror.New(apierror.InvalidRequest).With("reason", "name or base_path is required")
+		}
+		if !store.ValidDir(name) {
+			return models.Library{}, apierror.New(apierror.InvalidLibraryName)
+		}
+		path = filepath.Join(s.docRoot, name)
+		library.Path = path
+	}
+	blogDbPath := filepath.Join(path, "blog.db")
+
+	// An existing directory without a database is fine, it is filled in
+	if _, err := os.Stat(blogDbPath); err == nil {
+		return library, apierror.New(apierror.LibraryExists).With("path", path)
+	}
+
+	// Seed the new library from a template library instead of empty tables
+	if template != "" {
+		if !s.Exists(template) {
+			return library, libraryNotFound(template)
+		}
+		templateDB, release, err := s.openForCopy(template, path)
+		if err != nil {
+			return library, err
+		}
+		defer release()
+
+		if !isTemplateLibrary(ctx, templateDB) {
+			return library, apierror.New(apierror.NotATemplate).With("library", template)
+		}
+		// The library may have been created while we waited for the locks
+		if _, err := os.Stat(blogDbPath); err == nil {
+			return library, apierror.New(apierror.LibraryExists).With("path", path)
+		}
+		_, statErr := os.Stat(path)
+		existed := statErr == nil
+		if err := copyLibrary(ctx, templateDB, filepath.Join(s.docRoot, template), path); err != nil {
+			removeCopy(path, existed)
+			return library, fmt.Errorf("copy template: %w", err)
+		}
+		if err := resetCopiedLibrary(ctx, path, template, name); err != nil {
+			removeCopy(path, existed)
+			return library, fmt.Errorf("initialize config: %w", err)
+		}
+		library.Template = template
+		return library, nil
+	}
+
+	if err := os.MkdirAll(filepath.Join(path, "pic"), 0755); err != nil {
+		return library, err
+	}
+
+	db, err := sql.Open("sqlite", blogDbPath)
+	if err != nil {
+		return library, err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS documents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT,
+			content TEXT,
+			parent_id INTEGER
+		)
+	`)
+	if err != nil {
+		return library, fmt.Errorf("create documents table: %w", err)
+	}
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS config (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT,
+			key TEXT,
+			value TEXT
+		)
+	`)
+	if err != nil {
+		return library, fmt.Errorf("create config table: %w", err)
+	}
+
+	// Bring the new database up to the current schema version
+	if err := store.Migrate(db); err != nil {
+		return library, fmt.Errorf("migrate: %w", err)
+	}
+	if _, err := store.NewConfigRepository(db).Set(ctx, "blog", "name", name); err != nil {
+		return library, fmt.Errorf("initialize config: %w", err)
+	}
+	return library, nil
+}
+
+func (s *libraryService) Clone(ctx context.Context, source, dir, name string) (models.Library, error) {
+	if source == "" || dir == "" {
+		return models.Library{}, apierror.New(apierror.InvalidRequest).With("reason", "source and dir are required")
+	}
+	if !store.ValidDir(dir) {
+		return models.Library{}, apierror.New(apierror.InvalidLibraryName)
+	}
+	if !s.Exists(source) {
+		return models.Library{}, libraryNotFound(source)
+	}
+	if name == "" {
+		name = dir
+	}
+	dstPath := filepath.Join(s.docRoot, dir)
+	library := models.Library{Name: name, Dir: dir, Path: dstPath}
+
+	if _, err := os.Stat(dstPath); err == nil {
+		return library, apierror.New(apierror.LibraryExists).With("library", dir)
+	}
+
+	db, release, err := s.openForCopy(source, dstPath)
+	if err != nil {
+		return library, err
+	}
+	defer release()
+
+	// The target may have been created while we waited for the locks
+	if _, err := os.Stat(dstPath); err == nil {
+		return library, apierror.New(apierror.LibraryExists).With("library", dir)
+	}
+
+	if err := copyLibrary(ctx, db, filepath.Join(s.docRoot, source), dstPath); err != nil {
+		os.RemoveAll(dstPath)
+		return library, fmt.Errorf("copy library: %w", err)
+	}
+	if err := resetCopiedLibrary(ctx, dstPath, source, name); err != nil {
+		os.RemoveAll(dstPath)
+		return library, fmt.Errorf("initialize config: %w", err)
+	}
+	return library, nil
+}
+
+// openForCopy opens the source library for reading and keeps the target
+// directory reserved while it is being populated. Both are locked in path
+// order like Rename does, so a copy and a rename between the same two
+// libraries cannot deadlock.
+func (s *libraryService) openForCopy(source, targetPath string) (*sql.DB, func(), error) {
+	sourceKey, _ := filepath.Abs(filepath.Join(s.docRoot, source))
+	targetKey, _ := filepath.Abs(targetPath)
+
+	var releaseTarget func()
+	if targetKey < sourceKey {
+		releaseTarget = store.ExclusivePath(targetPath)
+	}
+	db, release, err := openLibrary(s.docRoot, source, false)
+	if err != nil {
+		if releaseTarget != nil {
+			releaseTarget()
+		}
+		return nil, nil, err
+	}
+	if releaseTarget == nil {
+		releaseTarget = store.ExclusivePath(targetPath)
+	}
+	return db, func() {
+		releaseTarget()
+		release()
+	}, nil
+}
+
+// removeCopy cleans up after a failed copy into libPath. A directory that
+// existed before is kept and only loses the new database.
+func removeCopy(libPath string, existed bool) {
+	if !existed {
+		os.RemoveAll(libPath)
+		return
+	}
+	for _, name := range []string{"blog.db", "blog.db-wal", "blog.db-shm"} {
+		os.Remove(filepath.Join(libPath, name))
+	}
+}
+
+func (s *libraryService) List(ctx context.Context, includeArchived bool) ([]models.Library, error) {
+	libraries := []models.Library{}
+
+	// Create a missing document root, there are no libraries in it yet
+	if _, err := os.Stat(s.docRoot); os.IsNotExist(err) {
+		return libraries, os.MkdirAll(s.docRoot, 0755)
+	}
+
+	// Only direct children of the document root can be libraries
+	entries, err := os.ReadDir(s.docRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !store.Exists(s.docRoot, entry.Name()) {
+			continue
+		}
+		library, ok := s.describe(ctx, entry.Name())
+		if library.Archived == "true" && !includeArchived {
+			continue
+		}
+		if !ok {
+			// Fall back to the directory name if the database cannot be opened
+			library = models.Library{Name: entry.Name(), Path: library.Path, Dir: entry.Name()}
+		}
+		libraries = append(libraries, library)
+	}
+	return libraries, nil
+}
+
+// describe reads the list entry of a library directly from its file, so
+// listing does not load every library into the pool. ok is false when the
+// database cannot be opened.
+func (s *libraryService) describe(ctx context.Context, dir string) (library models.Library, ok bool) {
+	libPath := filepath.Join(s.docRoot, dir)
+	library = models.Library{Name: dir, Path: libPath, Dir: dir}
+
+	db, err := sql.Open("sqlite", store.DSN(libPath))
+	if err != nil {
+		return library, false
+	}
+	defer db.Close()
+
+	config := store.NewConfigRepository(db)
+	if name, _ := config.Get(ctx, "blog", "name"); name != "" {
+		library.Name = name
+	}
+	if archived, _ := config.Get(ctx, "blog", "archived"); archived == "true" {
+		library.Archived = "true"
+	}
+	if isTemplateLibrary(ctx, db) {
+		library.Template = "true"
+	}
+	return library, true
+}
+
+func (s *libraryService) Rename(ctx context.Context, library, dir, name string) (models.Library, error) {
+	if dir == "" && name == "" {
+		return models.Library{}, apierror.New(apierror.NoChanges)
+	}
+	if dir != "" && !store.ValidDir(dir) {
+		return models.Library{}, apierror.New(apierror.InvalidLibraryName)
+	}
+	if !s.Exists(library) {
+		return models.Library{}, libraryNotFound(library)
+	}
+	if dir == library {
+		dir = ""
+	}
+	oldPath := filepath.Join(s.docRoot, library)
+	newPath := filepath.Join(s.docRoot, dir)
+	if dir != "" {
+		if _, err := os.Stat(newPath); err == nil {
+			return models.Library{}, apierror.New(apierror.LibraryExists).With("library", dir)
+		}
+	}
+
+	// Wait for in-flight requests and close the shared handles. The target
+	// name is held too so nothing opens it during the move. Libraries are
+	// always locked in name order, so two renames or a rename and a transfer
+	// between the same libraries cannot deadlock.
+	locked := []string{library}
+	if dir != "" {
+		locked = append(locked, dir)
+		slices.Sort(locked)
+	}
+	for _, l := range locked {
+		release := store.Exclusive(s.docRoot, l)
+		defer release()
+	}
+
+	newDir := library
+	if dir != "" {
+		// The target may have been created while we waited for the locks
+		if _, err := os.Stat(newPath); err == nil {
+			return models.Library{}, apierror.New(apierror.LibraryExists).With("library", dir)
+		}
+		if err := os.Rename(oldPath, newPath); err != nil {
+			return models.Library{}, fmt.Errorf("rename library directory: %w", err)
+		}
+		newDir = dir
+	}
+
+	// The display name is saved last, so a failed directory rename leaves
+	// the library untouched
+	if name != "" {
+		if err := setLibraryName(ctx, filepath.Join(s.docRoot, newDir), name); err != nil {
+			if newDir != library {
+				os.Rename(newPath, oldPath)
+			}
+			return models.Library{}, fmt.Errorf("update library name: %w", err)
+		}
+	}
+	// Report the stored display name, which is kept when only the
+	// directory changes
+	renamed, _ := s.describe(ctx, newDir)
+	return renamed, nil
+}
+
+// setLibraryName saves the display name of a library that is held exclusively
+func setLibraryName(ctx context.Context, libPath, name string) error {
+	db, err := store.OpenFile(libPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = store.NewConfigRepository(db).Set(ctx, "blog", "name", name)
+	return err
+}
+
+func (s *libraryService) Archive(ctx context.Context, library string, archived bool) error {
+	if !s.Exists(library) {
+		return libraryNotFound(library)
+	}
+
+	// Take the library exclusively so the next request reloads the flag
+	release := store.Exclusive(s.docRoot, library)
+	defer release()
+
+	db, err := store.OpenFile(filepath.Join(s.docRoot, library))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	value := "false"
+	if archived {
+		value = "true"
+	}
+	_, err = store.NewConfigRepository(db).Set(ctx, "blog", "archived", value)
+	return err
+}
+
+func (s *libraryService) Exists(library string) bool {
+	return store.ValidDir(library) && store.Exists(s.docRoot, library)
+}
+
+func (s *libraryService) Delete(ctx context.Context, library string) error {
+	if !s.Exists(library) {
+		return libraryNotFound(library)
+	}
+
+	release := store.Exclusive(s.docRoot, library)
+	defer release()
+
+	return os.RemoveAll(filepath.Join(s.docRoot, library))
+}
+
+// copyLibrary copies the database of an open library together with its pic
+// directory into dstPath. The database is copied with VACUUM INTO so the
+// snapshot is consistent even while other requests read the source.
+func copyLibrary(ctx context.Context, srcDB *sql.DB, srcPath, dstPath string) error {
+	if err := os.MkdirAll(dstPath, 0755); err != nil {
+		return err
+	}
+	if err := store.Snapshot(ctx, srcDB, filepath.Join(dstPath, "blog.db")); err != nil {
+		return err
+	}
+
+	srcPic := filepath.Join(srcPath, "pic")
+	dstPic := filepath.Join(dstPath, "pic")
+	if _, err := os.Stat(srcPic); os.IsNotExist(err) {
+		return os.MkdirAll(dstPic, 0755)
+	}
+	return store.CopyDir(srcPic, dstPic)
+}
+
+// resetCopiedLibrary sets the display name of a freshly copied library, points
+// image links in its documents at its own pic directory and clears the flags
+// that must not be inherited from its source
+func resetCopiedLibrary(ctx context.Context, libPath, srcDir, name string) error {
+	db, err := store.OpenFile(libPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	dstDir := filepath.Base(libPath)
+	if dstDir != srcDir {
+		err = store.NewDocumentRepository(db).ReplaceInContent(ctx, "/pic/"+srcDir+"/", "/pic/"+dstDir+"/")
+		if err != nil {
+			return err
+		}
+	}
+
+	config := store.NewConfigRepository(db)
+	for key, value := range map[string]string{"name": name, "template": "false", "archived": "false"} {
+		if _, err := config.Set(ctx, "blog", key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isTemplateLibrary reports whether the library has the blog.template flag set
+func isTemplateLibrary(ctx context.Context, db *sql.DB) bool {
+	template, _ := store.NewConfigRepository(db).Get(ctx, "blog", "template")
+	return template == "true"
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"main/apierror"
+	"main/models"
+	"main/store"
+)
+
+func (s *documentService) Backlinks(ctx context.Context, library string, id int64) ([]models.DocumentRef, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if id <= 0 {
+		return nil, apierror.New(apierror.DocumentIDRequired)
+	}
+	exists, err := store.NewDocumentRepository(db).Exists(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apierror.New(apierror.DocumentNotFound)
+	}
+	return store.NewLinkRepository(db).Backlinks(ctx, id)
+}
+
+func (s *documentService) BrokenLinks(ctx context.Context, library string, ids ...int64) ([]models.Link, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	broken, err := store.NewLinkRepository(db).Broken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(broken, func(link models.Link) bool {
+		return !slices.Contains(ids, link.Source)
+	}), nil
+}
+
+func (s *documentService) Graph(ctx context.Context, library string) (models.LinkGraph, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return models.LinkGraph{}, err
+	}
+	defer release()
+
+	docs, err := store.NewDocumentRepository(db).All(ctx)
+	if err != nil {
+		return models.LinkGraph{}, err
+	}
+	graph := models.LinkGraph{Nodes: make([]models.DocumentRef, len(docs))}
+	for i, doc := range docs {
+		graph.Nodes[i] = models.DocumentRef{ID: doc.ID, Title: doc.Title}
+	}
+	graph.Links, err = store.NewLinkRepository(db).All(ctx)
+	return graph, err
+}
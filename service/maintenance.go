@@ -0,0 +1,181 @@
+package service
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"main/store"
+)
+
+// LibraryStats holds the size of a library
+type LibraryStats struct {
+	Documents int64
+	PicBytes  int64 // Total size of the files in the pic directory
+}
+
+func (s *libraryService) Dirs() ([]string, error) {
+	entries, err := os.ReadDir(s.docRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && store.Exists(s.docRoot, entry.Name()) {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+func (s *libraryService) Migrate(ctx context.Context, library string) (from, to int, err error) {
+	if !s.Exists(library) {
+		return 0, 0, libraryNotFound(library)
+	}
+
+	// Hold the library so the version read and the migration see the same file
+	release := store.Exclusive(s.docRoot, library)
+	defer release()
+
+	db, err := sql.Open("sqlite", store.DSN(filepath.Join(s.docRoot, library)))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer db.Close()
+
+	if from, err = store.Version(db); err != nil {
+		return 0, 0, err
+	}
+	if err := store.Migrate(db); err != nil {
+		return from, from, err
+	}
+	return from, store.SchemaVersion(), nil
+}
+
+func (s *libraryService) IntegrityCheck(ctx context.Context, library string) ([]string, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return store.Check(ctx, db, false)
+}
+
+// Backup writes a gzipped tar archive of a library to w. The archive holds a
+// consistent snapshot of blog.db taken with VACUUM INTO and the pic
+// directory, both under the library directory name.
+func (s *libraryService) Backup(ctx context.Context, library string, w io.Writer) error {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	tmpDir, err := os.MkdirTemp("", "doc_admin-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	snapshot := filepath.Join(tmpDir, "blog.db")
+	if err := store.Snapshot(ctx, db, snapshot); err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := addFileToTar(tw, snapshot, filepath.Join(library, "blog.db")); err != nil {
+		return err
+	}
+
+	libPath := filepath.Join(s.docRoot, library)
+	picDir := filepath.Join(libPath, "pic")
+	err = filepath.WalkDir(picDir, func(path string, d os.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == picDir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(libPath, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(filepath.Join(library, rel)) + "/"
+			return tw.WriteHeader(header)
+		}
+		return addFileToTar(tw, path, filepath.Join(library, rel))
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// addFileToTar writes a regular file into the archive under name
+func addFileToTar(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(name)
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func (s *libraryService) Stats(ctx context.Context, library string) (LibraryStats, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return LibraryStats{}, err
+	}
+	var stats LibraryStats
+	stats.Documents, err = store.NewDocumentRepository(db).Count(ctx)
+	release()
+	if err != nil {
+		return LibraryStats{}, err
+	}
+
+	filepath.WalkDir(filepath.Join(s.docRoot, library, "pic"), func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			stats.PicBytes += info.Size()
+		}
+		return nil
+	})
+	return stats, nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"main/apierror"
+	"main/models"
+	"main/store"
+)
+
+// metaConfigName is the config name holding a library's metadata schema. A
+// library without meta.* entries accepts any metadata key.
+const metaConfigName = "meta"
+
+var (
+	metaKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,63}$`)
+	metaTypes      = []string{"string", "bool", "int", "date"}
+)
+
+// parseMetaField parses the config value of a metadata field,
+// <type>[:<allowed>,<allowed>...], e.g. "date" or "string:draft,review,done"
+func parseMetaField(key, spec string) (models.MetaField, error) {
+	if !metaKeyPattern.MatchString(key) {
+		return models.MetaField{}, errors.New("keys are letters, digits, _, . and -, at most 64 characters")
+	}
+	fieldType, allowed, hasAllowed := strings.Cut(spec, ":")
+	field := models.MetaField{Key: key, Type: fieldType}
+	if !slices.Contains(metaTypes, fieldType) {
+		return field, errors.New("type must be one of " + strings.Join(metaTypes, ", "))
+	}
+	if hasAllowed {
+		for _, value := range strings.Split(allowed, ",") {
+			if checkValue(apierror.MetaValueInvalid, fieldType, nil, value) != nil {
+				return field, errors.New("allowed value " + value + " is not a " + fieldType)
+			}
+			field.Allowed = append(field.Allowed, value)
+		}
+	}
+	return field, nil
+}
+
+// metaSchema returns the metadata fields of a library by key, nil if the
+// library has no schema
+func metaSchema(ctx context.Context, q store.Querier) (map[string]models.MetaField, error) {
+	config, err := store.NewConfigRepository(q).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]models.MetaField
+	for key, spec := range config[metaConfigName] {
+		// Values are checked when set through the API, skip broken ones
+		field, err := parseMetaField(key, spec)
+		if err != nil {
+			continue
+		}
+		if schema == nil {
+			schema = make(map[string]models.MetaField)
+		}
+		schema[key] = field
+	}
+	return schema, nil
+}
+
+// validateMeta checks metadata against the schema of a library. nil values
+// remove a key and are only checked for a valid key.
+func validateMeta(schema map[string]models.MetaField, meta map[string]*string) error {
+	for _, key := range slices.Sorted(maps.Keys(meta)) {
+		field, found := schema[key]
+		switch {
+		case schema == nil && !metaKeyPattern.MatchString(key):
+			return apierror.New(apierror.MetaKeyInvalid).With("key", key).With("reason", "keys are letters, digits, _, . and -, at most 64 characters")
+		// Keys dropped from the schema can still be removed
+		case schema != nil && !found && meta[key] != nil:
+			return apierror.New(apierror.MetaKeyInvalid).With("key", key).With("reason", "key is not in the metadata schema of the library")
+		}
+		if meta[key] == nil || !found {
+			continue
+		}
+		if err := checkValue(apierror.MetaValueInvalid, field.Type, field.Allowed, *meta[key]); err != nil {
+			return err.With("key", key)
+		}
+	}
+	return nil
+}
+
+func (s *documentService) MetaSchema(ctx context.Context, library string) ([]models.MetaField, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	schema, err := metaSchema(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	fields := []models.MetaField{}
+	for _, key := range slices.Sorted(maps.Keys(schema)) {
+		fields = append(fields, schema[key])
+	}
+	return fields, nil
+}
+
+func (s *documentService) SetMeta(ctx context.Context, library string, id int64, meta map[string]*string) (map[string]string, error) {
+	if len(meta) == 0 {
+		return nil, apierror.New(apierror.NoChanges)
+	}
+	db, release, err := openLibrary(s.docRoot, library, true)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if id <= 0 {
+		return nil, apierror.New(apierror.DocumentIDRequired)
+	}
+	var result map[string]string
+	err = store.InTx(ctx, db, func(tx *sql.Tx) error {
+		exists, err := store.NewDocumentRepository(tx).Exists(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return apierror.New(apierror.DocumentNotFound)
+		}
+		if err := setMeta(ctx, tx, id, meta); err != nil {
+			return err
+		}
+		result, err = store.NewMetaRepository(tx).ForDocument(ctx, id)
+		return err
+	})
+	return result, err
+}
+
+// setMeta validates and applies metadata changes of a document, nil values
+// remove their key
+func setMeta(ctx context.Context, tx *sql.Tx, id int64, meta map[string]*string) error {
+	schema, err := metaSchema(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err := validateMeta(schema, meta); err != nil {
+		return err
+	}
+	repo := store.NewMetaRepository(tx)
+	for key, value := range meta {
+		if value == nil {
+			err = repo.Delete(ctx, id, key)
+		} else {
+			err = repo.Set(ctx, id, key, *value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
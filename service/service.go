@@ -0,0 +1,45 @@
+// Package service holds the business logic behind the API, independent of
+// HTTP. Expected failures such as a missing document are returned as
+// *apierror.Error with a stable code, anything else is an internal error
+// the caller should log.
+package service
+
+import (
+	"database/sql"
+
+	"main/apierror"
+	"main/store"
+)
+
+// LibraryError translates the errors of store.Acquire into API errors.
+// Other errors are returned unchanged. name is added to the details of
+// LIBRARY_NOT_FOUND when it is not empty.
+func LibraryError(name string, err error) error {
+	switch err {
+	case store.ErrLibraryUnavailable:
+		return apierror.Wrap(apierror.LibraryUnavailable, err)
+	case store.ErrLibraryArchived:
+		return apierror.Wrap(apierror.LibraryArchived, err)
+	case store.ErrLibraryNotFound:
+		e := apierror.Wrap(apierror.LibraryNotFound, err)
+		if name != "" {
+			e.With("library", name)
+		}
+		return e
+	}
+	return err
+}
+
+// openLibrary acquires a library from the pool, see store.Acquire
+func openLibrary(docRoot, name string, write bool) (*sql.DB, func(), error) {
+	db, release, err := store.Acquire(docRoot, name, write)
+	if err != nil {
+		return nil, nil, LibraryError(name, err)
+	}
+	return db, release, nil
+}
+
+// invalidRequest returns an INVALID_REQUEST error with the reason in its details
+func invalidRequest(reason string) error {
+	return apierror.New(apierror.InvalidRequest).With("reason", reason)
+}
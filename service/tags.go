@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"main/apierror"
+	"main/models"
+	"main/store"
+)
+
+const maxTagLength = 64
+
+// normalizeTags trims tag names, checks them and drops duplicates, which
+// differ only in case
+func normalizeTags(names []string) ([]string, error) {
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		reason := ""
+		switch {
+		case name == "":
+			reason = "tag names must not be empty"
+		case utf8.RuneCountInString(name) > maxTagLength:
+			reason = "tag names are at most 64 characters"
+		case strings.ContainsFunc(name, func(r rune) bool { return r == ',' || unicode.IsControl(r) }):
+			reason = "tag names must not contain commas or control characters"
+		}
+		if reason != "" {
+			return nil, apierror.New(apierror.InvalidTag).With("tag", name).With("reason", reason)
+		}
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			tags = append(tags, name)
+		}
+	}
+	return tags, nil
+}
+
+func (s *documentService) Tags(ctx context.Context, library string) ([]models.Tag, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return store.NewTagRepository(db).All(ctx)
+}
+
+func (s *documentService) AddTags(ctx context.Context, library string, id int64, tags []string) ([]string, error) {
+	return s.changeTags(ctx, library, id, tags, true)
+}
+
+func (s *documentService) RemoveTags(ctx context.Context, library string, id int64, tags []string) ([]string, error) {
+	return s.changeTags(ctx, library, id, tags, false)
+}
+
+// changeTags adds or removes tags of a document and returns its tags
+func (s *documentService) changeTags(ctx context.Context, library string, id int64, names []string, add bool) ([]string, error) {
+	tags, err := normalizeTags(names)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, apierror.New(apierror.NoChanges)
+	}
+
+	db, release, err := openLibrary(s.docRoot, library, true)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if id <= 0 {
+		return nil, apierror.New(apierror.DocumentIDRequired)
+	}
+	var result []string
+	err = store.InTx(ctx, db, func(tx *sql.Tx) error {
+		exists, err := store.NewDocumentRepository(tx).Exists(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return apierror.New(apierror.DocumentNotFound)
+		}
+		repo := store.NewTagRepository(tx)
+		if add {
+			err = repo.Add(ctx, id, tags)
+		} else {
+			err = repo.Remove(ctx, id, tags)
+		}
+		if err != nil {
+			return err
+		}
+		result, err = repo.ForDocument(ctx, id)
+		return err
+	})
+	return result, err
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"main/apierror"
+	"main/models"
+	"main/store"
+)
+
+// subtreeOf returns the document with the given id and all of its
+// descendants among docs, parents always before their children
+func subtreeOf(docs []models.Document, rootID int64) ([]models.Document, bool) {
+	byID := make(map[int64]models.Document)
+	children := make(map[int64][]int64)
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+		children[doc.ParentID] = append(children[doc.ParentID], doc.ID)
+	}
+
+	root, ok := byID[rootID]
+	if !ok {
+		return nil, false
+	}
+
+	// Breadth-first walk; visited guards against existing cycles in the data
+	subtree := []models.Document{root}
+	visited := map[int64]bool{rootID: true}
+	for i := 0; i < len(subtree); i++ {
+		for _, childID := range children[subtree[i].ID] {
+			if visited[childID] {
+				continue
+			}
+			visited[childID] = true
+			subtree = append(subtree, byID[childID])
+		}
+	}
+	return subtree, true
+}
+
+// picLinkReplacer rewrites image links of the given documents from their old
+// location in the source library to the new one in the target library
+func picLinkReplacer(srcLibrary, dstLibrary string, idMap map[int64]int64) *strings.Replacer {
+	var pairs []string
+	for oldID, newID := range idMap {
+		pairs = append(pairs,
+			fmt.Sprintf("/pic/%s/%d/", srcLibrary, oldID),
+			fmt.Sprintf("/pic/%s/%d/", dstLibrary, newID))
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// transferLinks rewrites the document and [[#id]] wiki links of transferred
+// content. Links between transferred documents follow them to their new IDs,
+// links to documents left behind point into the source library.
+func transferLinks(content, srcLibrary string, idMap map[int64]int64, titles map[int64]string) string {
+	target := func(id int64) (store.LinkTarget, bool) {
+		if newID, ok := idMap[id]; ok {
+			return store.LinkTarget{ID: newID}, true
+		}
+		return store.LinkTarget{Library: srcLibrary, ID: id, Title: titles[id]}, true
+	}
+	return store.RewriteWikiLinks(store.RewriteLinks(content, target), target)
+}
+
+func (s *documentService) Transfer(ctx context.Context, source, target string, id, parentID int64, move bool) (map[int64]int64, error) {
+	if source == "" || target == "" {
+		return nil, apierror.New(apierror.LibraryRequired)
+	}
+	if source == target {
+		return nil, invalidRequest("source and target library must differ, use update-parent to move within a library")
+	}
+	if id <= 0 {
+		return nil, apierror.New(apierror.DocumentIDRequired)
+	}
+	for _, name := range []string{source, target} {
+		if !store.ValidDir(name) || !store.Exists(s.docRoot, name) {
+			return nil, libraryNotFound(name)
+		}
+	}
+
+	// Always acquire libraries in name order, the order Rename locks them
+	// in, so opposite transfers cannot deadlock against each other or
+	// against a pending rename or delete
+	first, second := source, target
+	if second < first {
+		first, second = second, first
+	}
+	dbs := make(map[string]*sql.DB)
+	for _, name := range []string{first, second} {
+		db, release, err := openLibrary(s.docRoot, name, name == target || move)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		dbs[name] = db
+	}
+	srcDB, dstDB := dbs[source], dbs[target]
+
+	all, err := store.NewDocumentRepository(srcDB).All(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read documents: %w", err)
+	}
+	subtree, ok := subtreeOf(all, id)
+	if !ok {
+		return nil, apierror.New(apierror.DocumentNotFound)
+	}
+	titles := make(map[int64]string, len(all))
+	for _, doc := range all {
+		titles[doc.ID] = doc.Title
+	}
+
+	ids := make([]int64, len(subtree))
+	for i, doc := range subtree {
+		ids[i] = doc.ID
+	}
+	tags, err := store.NewTagRepository(srcDB).ForDocuments(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("read tags: %w", err)
+	}
+	// Metadata is copied as is, even if the target library has another schema
+	meta, err := store.NewMetaRepository(srcDB).ForDocuments(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("read metadata: %w", err)
+	}
+
+	if parentID != 0 {
+		exists, err := store.NewDocumentRepository(dstDB).Exists(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, apierror.New(apierror.ParentNotFound).With("parent_id", parentID)
+		}
+	}
+
+	tx, err := dstDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	txDocs := store.NewDocumentRepository(tx)
+	txTags := store.NewTagRepository(tx)
+	txMeta := store.NewMetaRepository(tx)
+
+	// Insert parents before children so every parent_id can be remapped
+	idMap := make(map[int64]int64, len(subtree))
+	for i, doc := range subtree {
+		newParentID := parentID
+		if i > 0 {
+			newParentID = idMap[doc.ParentID]
+		}
+		newID, err := txDocs.Create(ctx, models.Document{Title: doc.Title, Content: doc.Content, ParentID: newParentID})
+		if err != nil {
+			return nil, fmt.Errorf("insert document %d: %w", doc.ID, err)
+		}
+		if err := txTags.Add(ctx, newID, tags[doc.ID]); err != nil {
+			return nil, fmt.Errorf("tag document %d: %w", doc.ID, err)
+		}
+		for key, value := range meta[doc.ID] {
+			if err := txMeta.Set(ctx, newID, key, value); err != nil {
+				return nil, fmt.Errorf("copy metadata of document %d: %w", doc.ID, err)
+			}
+		}
+		idMap[doc.ID] = newID
+	}
+
+	// Now that all new IDs are known, rewrite image and document links in the content
+	replacer := picLinkReplacer(source, target, idMap)
+	for _, doc := range subtree {
+		content := transferLinks(replacer.Replace(doc.Content), source, idMap, titles)
+		if content == doc.Content {
+			continue
+		}
+		if _, err := txDocs.Update(ctx, idMap[doc.ID], nil, &content); err != nil {
+			return nil, fmt.Errorf("update document %d: %w", doc.ID, err)
+		}
+	}
+
+	// Copy image folders to the new IDs, cleaning up if anything fails
+	srcPic := filepath.Join(s.docRoot, source, "pic")
+	dstPic := filepath.Join(s.docRoot, target, "pic")
+	var copied []string
+	for oldID, newID := range idMap {
+		src := filepath.Join(srcPic, fmt.Sprint(oldID))
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		dst := filepath.Join(dstPic, fmt.Sprint(newID))
+		copied = append(copied, dst)
+		if err := store.CopyDir(src, dst); err != nil {
+			for _, dir := range copied {
+				os.RemoveAll(dir)
+			}
+			return nil, fmt.Errorf("copy images of document %d: %w", oldID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		for _, dir := range copied {
+			os.RemoveAll(dir)
+		}
+		return nil, err
+	}
+
+	if move {
+		if err := deleteSubtree(ctx, srcDB, subtree, target, idMap, titles); err != nil {
+			return idMap, apierror.Wrap(apierror.TransferIncomplete, err).With("id", idMap[id])
+		}
+		for _, doc := range subtree {
+			os.RemoveAll(filepath.Join(srcPic, fmt.Sprint(doc.ID)))
+		}
+	}
+	return idMap, nil
+}
+
+// deleteSubtree removes the moved documents in a single transaction and points
+// the document and [[#id]] wiki links of the remaining documents to them at
+// their new IDs in dstLibrary
+func deleteSubtree(ctx context.Context, db *sql.DB, subtree []models.Document, dstLibrary string, idMap map[int64]int64, titles map[int64]string) error {
+	target := func(id int64) (store.LinkTarget, bool) {
+		newID, ok := idMap[id]
+		return store.LinkTarget{Library: dstLibrary, ID: newID, Title: titles[id]}, ok
+	}
+	return store.InTx(ctx, db, func(tx *sql.Tx) error {
+		docs := store.NewDocumentRepository(tx)
+		// Wiki links are not tracked, so every remaining document is checked
+		all, err := docs.All(ctx)
+		if err != nil {
+			return err
+		}
+		for _, doc := range all {
+			if _, moved := idMap[doc.ID]; moved {
+				continue
+			}
+			content := store.RewriteWikiLinks(store.RewriteLinks(doc.Content, target), target)
+			if content == doc.Content {
+				continue
+			}
+			if _, err := docs.Update(ctx, doc.ID, nil, &content); err != nil {
+				return err
+			}
+		}
+		for _, doc := range subtree {
+			if err := docs.Delete(ctx, doc.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
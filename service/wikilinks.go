@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"main/apierror"
+	"main/models"
+	"main/store"
+)
+
+// documentRefs returns the ID and title of every document of a library, for
+// resolving wiki links
+func documentRefs(ctx context.Context, docs *store.DocumentRepository) ([]models.DocumentRef, error) {
+	all, err := docs.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]models.DocumentRef, len(all))
+	for i, doc := range all {
+		refs[i] = models.DocumentRef{ID: doc.ID, Title: doc.Title}
+	}
+	return refs, nil
+}
+
+func (s *documentService) Render(ctx context.Context, library string, id int64) (models.RenderedDocument, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return models.RenderedDocument{}, err
+	}
+	defer release()
+
+	docs := store.NewDocumentRepository(db)
+	doc, err := docs.Get(ctx, id)
+	if err == store.ErrNotFound {
+		return models.RenderedDocument{}, apierror.New(apierror.DocumentNotFound)
+	}
+	if err != nil {
+		return models.RenderedDocument{}, err
+	}
+	refs, err := documentRefs(ctx, docs)
+	if err != nil {
+		return models.RenderedDocument{}, err
+	}
+
+	content, links := store.ResolveWikiLinks(doc.Content, refs)
+	if links == nil {
+		links = []models.WikiLink{}
+	}
+	return models.RenderedDocument{ID: doc.ID, Title: doc.Title, Content: content, WikiLinks: links}, nil
+}
+
+func (s *documentService) RenderAll(ctx context.Context, library string) ([]models.RenderedDocument, error) {
+	db, release, err := openLibrary(s.docRoot, library, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	all, err := store.NewDocumentRepository(db).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]models.DocumentRef, len(all))
+	for i, doc := range all {
+		refs[i] = models.DocumentRef{ID: doc.ID, Title: doc.Title}
+	}
+
+	rendered := make([]models.RenderedDocument, len(all))
+	for i, doc := range all {
+		content, links := store.ResolveWikiLinks(doc.Content, refs)
+		if links == nil {
+			links = []models.WikiLink{}
+		}
+		rendered[i] = models.RenderedDocument{ID: doc.ID, Title: doc.Title, Content: content, WikiLinks: links}
+	}
+	return rendered, nil
+}
+
+func (s *documentService) CreateStubs(ctx context.Context, library string, id, parentID int64) (map[string]int64, error) {
+	db, release, err := openLibrary(s.docRoot, library, true)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if id <= 0 {
+		return nil, apierror.New(apierror.DocumentIDRequired)
+	}
+	created := map[string]int64{}
+	err = store.InTx(ctx, db, func(tx *sql.Tx) error {
+		docs := store.NewDocumentRepository(tx)
+		doc, err := docs.Get(ctx, id)
+		if err == store.ErrNotFound {
+			return apierror.New(apierror.DocumentNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if parentID != 0 {
+			exists, err := docs.Exists(ctx, parentID)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return apierror.New(apierror.ParentNotFound).With("parent_id", parentID)
+			}
+		}
+		refs, err := documentRefs(ctx, docs)
+		if err != nil {
+			return err
+		}
+
+		// Missing IDs cannot be created, only missing titles
+		_, links := store.ResolveWikiLinks(doc.Content, refs)
+		for _, link := range links {
+			if link.Status != models.WikiLinkUnresolved || strings.HasPrefix(link.Target, "#") {
+				continue
+			}
+			stubID, err := docs.Create(ctx, models.Document{Title: link.Target, ParentID: parentID})
+			if err != nil {
+				return err
+			}
+			created[link.Target] = stubID
+		}
+		return nil
+	})
+	return created, err
+}
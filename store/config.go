@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// ConfigRepository reads and writes the config table of a library
+type ConfigRepository struct {
+	q Querier
+}
+
+// NewConfigRepository returns a repository on a library database or transaction
+func NewConfigRepository(q Querier) *ConfigRepository {
+	return &ConfigRepository{q: q}
+}
+
+// All returns the config as a name -> key -> value map
+func (r *ConfigRepository) All(ctx context.Context) (map[string]map[string]string, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT name, key, value FROM config ORDER BY name, key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	config := make(map[string]map[string]string)
+	for rows.Next() {
+		var name, key, value string
+		if err := rows.Scan(&name, &key, &value); err != nil {
+			continue
+		}
+		if _, ok := config[name]; !ok {
+			config[name] = make(map[string]string)
+		}
+		config[name][key] = value
+	}
+	return config, rows.Err()
+}
+
+// Get returns a single value, or an empty string when it is not set
+func (r *ConfigRepository) Get(ctx context.Context, name, key string) (string, error) {
+	var value string
+	row := r.q.QueryRowContext(ctx, "SELECT value FROM config WHERE name = ? AND key = ? LIMIT 1", name, key)
+	if err := row.Scan(&value); err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	return value, nil
+}
+
+// Set inserts or updates a value in one statement, relying on the
+// (name, key) unique index, and reports whether a row was written
+func (r *ConfigRepository) Set(ctx context.Context, name, key, value string) (bool, error) {
+	res, err := r.q.ExecContext(ctx, `
+		INSERT INTO config (name, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (name, key) DO UPDATE SET value = excluded.value
+	`, name, key, value)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// Delete removes a value and reports whether it existed
+func (r *ConfigRepository) Delete(ctx context.Context, name, key string) (bool, error) {
+	res, err := r.q.ExecContext(ctx, "DELETE FROM config WHERE name = ? AND key = ?", name, key)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteAllExcept removes every value except the given name/key pairs
+func (r *ConfigRepository) DeleteAllExcept(ctx context.Context, keep [][2]string) error {
+	var conditions []string
+	var args []any
+	for _, k := range keep {
+		conditions = append(conditions, "(name = ? AND key = ?)")
+		args = append(args, k[0], k[1])
+	}
+	query := "DELETE FROM config"
+	if len(conditions) > 0 {
+		query += " WHERE NOT (" + strings.Join(conditions, " OR ") + ")"
+	}
+	_, err := r.q.ExecContext(ctx, query, args...)
+	return err
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"main/metrics"
+
+	"modernc.org/sqlite"
+)
+
+// openInstrumented opens a library database whose statements are timed
+// into the per-library query duration metric
+func openInstrumented(dsn, library string) *sql.DB {
+	return sql.OpenDB(&libraryConnector{dsn: dsn, library: library, driver: &sqlite.Driver{}})
+}
+
+// libraryConnector creates connections labelled with their library
+type libraryConnector struct {
+	dsn     string
+	library string
+	driver  driver.Driver
+}
+
+func (c *libraryConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn, library: c.library}, nil
+}
+
+func (c *libraryConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// instrumentedConn wraps a driver connection and times queries, execs and
+// transaction starts. Optional driver interfaces are forwarded when the
+// underlying connection implements them.
+type instrumentedConn struct {
+	driver.Conn
+	library string
+}
+
+func (c *instrumentedConn) observe(op string, start time.Time) {
+	metrics.ObserveDBQuery(c.library, op, time.Since(start))
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer c.observe("query", time.Now())
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer c.observe("exec", time.Now())
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	defer c.observe("begin", time.Now())
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *instrumentedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,191 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"main/models"
+)
+
+// DocumentColumns are the columns List can read, in response order
+var DocumentColumns = []string{"id", "title", "content", "parent_id", "created_at", "updated_at"}
+
+// DocumentSorts are the columns List can sort by. Every sort is made unique
+// by the document ID.
+var DocumentSorts = []string{"id", "title", "updated_at"}
+
+// DocumentQuery selects a page of documents
+type DocumentQuery struct {
+	// Columns to read, id is always read. Empty reads all columns.
+	Columns []string
+	// Sort is one of DocumentSorts, Desc reverses it
+	Sort string
+	Desc bool
+	// After continues behind the last document of the previous page
+	After *DocumentKey
+	// Filters, ignored when empty
+	ParentID      *int64
+	TitlePrefix   string
+	UpdatedAfter  string   // Inclusive, see Timestamp
+	UpdatedBefore string   // Exclusive
+	Tags          []string // Documents must carry all of them
+	// Metadata key -> values, documents must have one of the values of every key
+	Meta  map[string][]string
+	Limit int
+}
+
+// DocumentKey is the position of a document in a sort order
+type DocumentKey struct {
+	Value string `json:"v,omitempty"` // Sort column, unused when sorting by id
+	ID    int64  `json:"i"`
+}
+
+// Key returns the position of doc in the sort order of the query
+func (q DocumentQuery) Key(doc models.Document) DocumentKey {
+	key := DocumentKey{ID: doc.ID}
+	switch q.Sort {
+	case "title":
+		key.Value = doc.Title
+	case "updated_at":
+		key.Value = doc.UpdatedAt
+	}
+	return key
+}
+
+// List returns the documents matching query, in its sort order. Columns
+// that were not read are left empty.
+func (r *DocumentRepository) List(ctx context.Context, query DocumentQuery) ([]models.Document, error) {
+	docs := []models.Document{}
+	if ok, err := r.hasTable(ctx); err != nil || !ok {
+		return docs, err
+	}
+
+	// The sort column is part of the SQL, not a parameter
+	if !slices.Contains(DocumentSorts, query.Sort) {
+		return nil, fmt.Errorf("invalid sort column %q", query.Sort)
+	}
+
+	columns := query.Columns
+	if len(columns) == 0 {
+		columns = DocumentColumns
+	}
+	selected := map[string]bool{"id": true, query.Sort: true}
+	for _, col := range columns {
+		selected[col] = true
+	}
+	var exprs []string
+	for _, col := range DocumentColumns {
+		if !selected[col] {
+			continue
+		}
+		switch col {
+		case "id":
+			exprs = append(exprs, col)
+		case "parent_id":
+			exprs = append(exprs, "COALESCE(parent_id, 0)")
+		default:
+			exprs = append(exprs, "COALESCE("+col+", '')")
+		}
+	}
+
+	var where []string
+	var args []any
+	if query.ParentID != nil {
+		where = append(where, "parent_id = ?")
+		args = append(args, *query.ParentID)
+	}
+	if query.TitlePrefix != "" {
+		where = append(where, `title LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(query.TitlePrefix)+"%")
+	}
+	if query.UpdatedAfter != "" {
+		where = append(where, "updated_at >= ?")
+		args = append(args, query.UpdatedAfter)
+	}
+	if query.UpdatedBefore != "" {
+		where = append(where, "updated_at < ?")
+		args = append(args, query.UpdatedBefore)
+	}
+
+	for _, tag := range query.Tags {
+		where = append(where, "id IN (SELECT dt.document_id FROM document_tags dt JOIN tags t ON t.id = dt.tag_id WHERE t.name = ?)")
+		args = append(args, tag)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(query.Meta)) {
+		values := query.Meta[key]
+		if len(values) == 0 {
+			continue
+		}
+		where = append(where, "id IN (SELECT document_id FROM document_meta WHERE key = ? AND value IN (?"+strings.Repeat(", ?", len(values)-1)+"))")
+		args = append(args, key)
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+
+	op, dir := ">", "ASC"
+	if query.Desc {
+		op, dir = "<", "DESC"
+	}
+	// NULLs sort as the empty string they are returned as, so the cursor
+	// compares the same values the page was ordered by
+	sortExpr := "COALESCE(" + query.Sort + ", '')"
+	order := "id " + dir
+	if query.Sort != "id" {
+		order = sortExpr + " " + dir + ", " + order
+	}
+	if query.After != nil {
+		if query.Sort == "id" {
+			where = append(where, "id "+op+" ?")
+			args = append(args, query.After.ID)
+		} else {
+			where = append(where, fmt.Sprintf("(%s, id) %s (?, ?)", sortExpr, op))
+			args = append(args, query.After.Value, query.After.ID)
+		}
+	}
+
+	sqlQuery := "SELECT " + strings.Join(exprs, ", ") + " FROM documents"
+	if len(where) > 0 {
+		sqlQuery += " WHERE " + strings.Join(where, " AND ")
+	}
+	sqlQuery += " ORDER BY " + order + " LIMIT ?"
+	args = append(args, query.Limit)
+
+	rows, err := r.q.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var doc models.Document
+		fields := map[string]any{
+			"id":         &doc.ID,
+			"title":      &doc.Title,
+			"content":    &doc.Content,
+			"parent_id":  &doc.ParentID,
+			"created_at": &doc.CreatedAt,
+			"updated_at": &doc.UpdatedAt,
+		}
+		var dest []any
+		for _, col := range DocumentColumns {
+			if selected[col] {
+				dest = append(dest, fields[col])
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
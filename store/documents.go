@@ -0,0 +1,232 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"main/models"
+)
+
+// TimestampFormat is the format of created_at and updated_at. It has a fixed
+// width in UTC, so timestamps sort correctly as strings.
+const TimestampFormat = "2006-01-02T15:04:05.000Z"
+
+// Timestamp formats t for comparison with created_at and updated_at
+func Timestamp(t time.Time) string {
+	return t.UTC().Format(TimestampFormat)
+}
+
+// DocumentRepository reads and writes the documents table of a library
+type DocumentRepository struct {
+	q Querier
+}
+
+// NewDocumentRepository returns a repository on a library database or transaction
+func NewDocumentRepository(q Querier) *DocumentRepository {
+	return &DocumentRepository{q: q}
+}
+
+// timestampColumns selects the timestamps, which are NULL for rows written
+// by other tools
+const timestampColumns = "COALESCE(created_at, ''), COALESCE(updated_at, '')"
+
+// hasTable reports whether the documents table exists. Databases that were
+// not created by doc_admin may lack it.
+func (r *DocumentRepository) hasTable(ctx context.Context) (bool, error) {
+	var count int
+	row := r.q.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master WHERE type='table' AND name='documents'")
+	if err := row.Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// All returns every document of the library
+func (r *DocumentRepository) All(ctx context.Context) ([]models.Document, error) {
+	docs := []models.Document{}
+	if ok, err := r.hasTable(ctx); err != nil || !ok {
+		return docs, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, "SELECT id, title, content, parent_id, "+timestampColumns+" FROM documents")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var doc models.Document
+		if err := rows.Scan(&doc.ID, &doc.Title, &doc.Content, &doc.ParentID, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
+			continue // Skip documents with scan errors
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// Outline returns the ID, parent and content of every document. Unlike All
+// it reads NULL columns as empty instead of skipping the row, so checks see
+// damaged documents too.
+func (r *DocumentRepository) Outline(ctx context.Context) ([]models.Document, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT id, COALESCE(parent_id, 0), COALESCE(content, '') FROM documents")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []models.Document
+	for rows.Next() {
+		var doc models.Document
+		if err := rows.Scan(&doc.ID, &doc.ParentID, &doc.Content); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// Count returns the number of documents
+func (r *DocumentRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM documents").Scan(&count)
+	return count, err
+}
+
+// Get returns a single document, or ErrNotFound
+func (r *DocumentRepository) Get(ctx context.Context, id int64) (models.Document, error) {
+	var doc models.Document
+	if ok, err := r.hasTable(ctx); err != nil || !ok {
+		if err == nil {
+			err = ErrNotFound
+		}
+		return doc, err
+	}
+
+	row := r.q.QueryRowContext(ctx, "SELECT id, title, content, parent_id, "+timestampColumns+" FROM documents WHERE id = ?", id)
+	err := row.Scan(&doc.ID, &doc.Title, &doc.Content, &doc.ParentID, &doc.CreatedAt, &doc.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return doc, ErrNotFound
+	}
+	return doc, err
+}
+
+// Exists reports whether a document exists
+func (r *DocumentRepository) Exists(ctx context.Context, id int64) (bool, error) {
+	var exists bool
+	row := r.q.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM documents WHERE id = ?)", id)
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+// ParentID returns the parent of a document, or ErrNotFound
+func (r *DocumentRepository) ParentID(ctx context.Context, id int64) (int64, error) {
+	var parentID int64
+	err := r.q.QueryRowContext(ctx, "SELECT parent_id FROM documents WHERE id = ?", id).Scan(&parentID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	return parentID, err
+}
+
+// Create inserts a document and returns its ID
+func (r *DocumentRepository) Create(ctx context.Context, doc models.Document) (int64, error) {
+	now := Timestamp(time.Now())
+	res, err := r.q.ExecContext(ctx, "INSERT INTO documents (title, content, parent_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		doc.Title, doc.Content, doc.ParentID, now, now)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, NewLinkRepository(r.q).Set(ctx, id, doc.Content)
+}
+
+// Update sets the title and/or content of a document, leaving nil fields
+// unchanged, and reports whether a row was changed. New content replaces the
+// links of the document.
+func (r *DocumentRepository) Update(ctx context.Context, id int64, title, content *string) (bool, error) {
+	var fields []string
+	var args []any
+	if title != nil {
+		fields = append(fields, "title = ?")
+		args = append(args, *title)
+	}
+	if content != nil {
+		fields = append(fields, "content = ?")
+		args = append(args, *content)
+	}
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	fields = append(fields, "updated_at = ?")
+	args = append(args, Timestamp(time.Now()), id)
+	res, err := r.q.ExecContext(ctx, "UPDATE documents SET "+strings.Join(fields, ", ")+" WHERE id = ?", args...)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	if n > 0 && content != nil {
+		if err := NewLinkRepository(r.q).Set(ctx, id, *content); err != nil {
+			return false, err
+		}
+	}
+	return n > 0, nil
+}
+
+// SetParent moves a document under another parent, 0 for the root
+func (r *DocumentRepository) SetParent(ctx context.Context, id, parentID int64) error {
+	_, err := r.q.ExecContext(ctx, "UPDATE documents SET parent_id = ?, updated_at = ? WHERE id = ?", parentID, Timestamp(time.Now()), id)
+	return err
+}
+
+// Delete removes a single document with its tags, metadata and links
+func (r *DocumentRepository) Delete(ctx context.Context, id int64) error {
+	if _, err := r.q.ExecContext(ctx, "DELETE FROM documents WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := NewMetaRepository(r.q).RemoveDocument(ctx, id); err != nil {
+		return err
+	}
+	if err := NewLinkRepository(r.q).RemoveDocument(ctx, id); err != nil {
+		return err
+	}
+	return NewTagRepository(r.q).RemoveDocument(ctx, id)
+}
+
+// ReplaceInContent replaces every occurrence of old in the content of all documents
+func (r *DocumentRepository) ReplaceInContent(ctx context.Context, old, new string) error {
+	_, err := r.q.ExecContext(ctx, "UPDATE documents SET content = REPLACE(content, ?, ?), updated_at = ? WHERE instr(content, ?) > 0",
+		old, new, Timestamp(time.Now()), old)
+	return err
+}
+
+// SubtreeIDs returns the ID of a document and of all its descendants. Existing
+// cycles in the data do not make it loop.
+func (r *DocumentRepository) SubtreeIDs(ctx context.Context, id int64) ([]int64, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		WITH RECURSIVE subtree(id) AS (
+			SELECT id FROM documents WHERE id = ?
+			UNION
+			SELECT d.id FROM documents d JOIN subtree s ON d.parent_id = s.id
+		)
+		SELECT id FROM subtree`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Exists reports whether dir is a library directory under docRoot.
+// The libraries are exactly the directories that contain a blog.db file.
+func Exists(docRoot, dir string) bool {
+	info, err := os.Stat(filepath.Join(docRoot, dir, "blog.db"))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// OpenFile opens a library database directly, bypassing the pool. It is
+// used by management operations while they hold the library exclusively.
+func OpenFile(libPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", DSN(libPath))
+	if err != nil {
+		return nil, err
+	}
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Check runs PRAGMA integrity_check, or the faster quick_check when quick is
+// set, and returns the problems it reports, none when the database is intact
+func Check(ctx context.Context, db *sql.DB, quick bool) ([]string, error) {
+	pragma := "PRAGMA integrity_check"
+	if quick {
+		pragma = "PRAGMA quick_check"
+	}
+	rows, err := db.QueryContext(ctx, pragma)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// Snapshot writes a consistent copy of a library database to path with
+// VACUUM INTO, even while other requests use it
+func Snapshot(ctx context.Context, db *sql.DB, path string) error {
+	_, err := db.ExecContext(ctx, "VACUUM INTO ?", path)
+	return err
+}
+
+// CopyDir recursively copies the contents of src into dst
+func CopyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(path, target)
+	})
+}
+
+// copyFile copies a single regular file
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"main/models"
+)
+
+// linkPattern matches internal links, /document?id=<id> with any other query
+// parameters, with or without the /api prefix
+var linkPattern = regexp.MustCompile(`/document\?([\w.~%+=&-]*)`)
+
+// parseLink returns the target of an internal link query. Links with a
+// library parameter point into another library and are not tracked.
+func parseLink(query string) (int64, bool) {
+	values, _ := url.ParseQuery(query)
+	if values.Has("library") {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(values.Get("id"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// ParseLinks returns the IDs of the documents content links to, sorted and
+// without duplicates
+func ParseLinks(content string) []int64 {
+	var ids []int64
+	for _, m := range linkPattern.FindAllStringSubmatch(content, -1) {
+		if id, ok := parseLink(m[1]); ok {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// LinkTarget is the new target of a rewritten link, Library is set for
+// documents in another library
+type LinkTarget struct {
+	Library string
+	ID      int64
+	Title   string // Label of wiki links that become /document links
+}
+
+// RewriteLinks points the internal links of content for which link returns
+// a target at that target, e.g. when their documents get new IDs. Only the
+// id parameter is replaced, with a library parameter in front of it for
+// targets in another library. Other parameters are kept as they are.
+func RewriteLinks(content string, link func(id int64) (LinkTarget, bool)) string {
+	return linkPattern.ReplaceAllStringFunc(content, func(match string) string {
+		query := linkPattern.FindStringSubmatch(match)[1]
+		id, ok := parseLink(query)
+		if !ok {
+			return match
+		}
+		target, ok := link(id)
+		if !ok {
+			return match
+		}
+
+		params := strings.Split(query, "&")
+		for i, param := range params {
+			key, _, _ := strings.Cut(param, "=")
+			if key, err := url.QueryUnescape(key); err != nil || key != "id" {
+				continue
+			}
+			params[i] = "id=" + strconv.FormatInt(target.ID, 10)
+			if target.Library != "" {
+				params[i] = "library=" + url.QueryEscape(target.Library) + "&" + params[i]
+			}
+			break
+		}
+		return match[:len(match)-len(query)] + strings.Join(params, "&")
+	})
+}
+
+// LinkRepository reads and writes the links between a library's documents,
+// which are parsed from their content whenever it is saved
+type LinkRepository struct {
+	q Querier
+}
+
+// NewLinkRepository returns a repository on a library database or transaction
+func NewLinkRepository(q Querier) *LinkRepository {
+	return &LinkRepository{q: q}
+}
+
+// Set replaces the links of a document with the ones in its content. Wiki
+// links by title are recorded when they name exactly one document at the
+// time of writing.
+func (r *LinkRepository) Set(ctx context.Context, id int64, content string) error {
+	return r.set(ctx, id, content, nil)
+}
+
+// set is Set with the documents to resolve wiki links by title against,
+// they are loaded when needed if refs is nil
+func (r *LinkRepository) set(ctx context.Context, id int64, content string, refs []models.DocumentRef) error {
+	if err := r.RemoveDocument(ctx, id); err != nil {
+		return err
+	}
+	targets := ParseLinks(content)
+	wikiIDs, titles := ParseWikiLinks(content)
+	targets = append(targets, wikiIDs...)
+	if titles {
+		if refs == nil {
+			var err error
+			if refs, err = r.documentRefs(ctx); err != nil {
+				return err
+			}
+		}
+		_, links := ResolveWikiLinks(content, refs)
+		for _, link := range links {
+			if link.Status == models.WikiLinkResolved {
+				targets = append(targets, link.ID)
+			}
+		}
+	}
+	slices.Sort(targets)
+	for _, target := range slices.Compact(targets) {
+		if _, err := r.q.ExecContext(ctx, "INSERT INTO links (source_id, target_id) VALUES (?, ?)", id, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// documentRefs returns the ID and title of every document, for resolving
+// wiki links by title
+func (r *LinkRepository) documentRefs(ctx context.Context) ([]models.DocumentRef, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT id, COALESCE(title, '') FROM documents")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := []models.DocumentRef{}
+	for rows.Next() {
+		var ref models.DocumentRef
+		if err := rows.Scan(&ref.ID, &ref.Title); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// RemoveDocument removes the links of a deleted document. Links to it are
+// kept, they are broken now.
+func (r *LinkRepository) RemoveDocument(ctx context.Context, id int64) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM links WHERE source_id = ?", id)
+	return err
+}
+
+// Backlinks returns the documents linking to a document, ordered by title
+func (r *LinkRepository) Backlinks(ctx context.Context, id int64) ([]models.DocumentRef, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT d.id, d.title FROM links l JOIN documents d ON d.id = l.source_id
+		WHERE l.target_id = ? ORDER BY d.title, d.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := []models.DocumentRef{}
+	for rows.Next() {
+		var ref models.DocumentRef
+		if err := rows.Scan(&ref.ID, &ref.Title); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// All returns every link of the library, ordered by source and target.
+// Broken is set on links to documents that do not exist.
+func (r *LinkRepository) All(ctx context.Context) ([]models.Link, error) {
+	return r.query(ctx, "")
+}
+
+// Broken returns the links to documents that do not exist
+func (r *LinkRepository) Broken(ctx context.Context) ([]models.Link, error) {
+	return r.query(ctx, "WHERE d.id IS NULL")
+}
+
+func (r *LinkRepository) query(ctx context.Context, where string) ([]models.Link, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT l.source_id, l.target_id, d.id IS NULL FROM links l LEFT JOIN documents d ON d.id = l.target_id
+		`+where+` ORDER BY l.source_id, l.target_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.Link{}
+	for rows.Next() {
+		var link models.Link
+		if err := rows.Scan(&link.Source, &link.Target, &link.Broken); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// backfillLinks fills the links table from the content of existing documents
+func backfillLinks(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, COALESCE(content, '') FROM documents")
+	if err != nil {
+		return err
+	}
+	contents := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		contents[id] = content
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	links := NewLinkRepository(tx)
+	refs, err := links.documentRefs(context.Background())
+	if err != nil {
+		return err
+	}
+	for id, content := range contents {
+		if err := links.set(context.Background(), id, content, refs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
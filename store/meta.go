@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+)
+
+// MetaRepository reads and writes the key/value metadata of a library's documents
+type MetaRepository struct {
+	q Querier
+}
+
+// NewMetaRepository returns a repository on a library database or transaction
+func NewMetaRepository(q Querier) *MetaRepository {
+	return &MetaRepository{q: q}
+}
+
+// ForDocuments returns the metadata of the given documents by document ID.
+// Documents without metadata are left out.
+func (r *MetaRepository) ForDocuments(ctx context.Context, ids []int64) (map[int64]map[string]string, error) {
+	meta := make(map[int64]map[string]string)
+	err := inChunks(ids, func(placeholders string, args []any) error {
+		rows, err := r.q.QueryContext(ctx, "SELECT document_id, key, value FROM document_meta WHERE document_id IN ("+placeholders+")", args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id int64
+			var key, value string
+			if err := rows.Scan(&id, &key, &value); err != nil {
+				return err
+			}
+			if meta[id] == nil {
+				meta[id] = make(map[string]string)
+			}
+			meta[id][key] = value
+		}
+		return rows.Err()
+	})
+	return meta, err
+}
+
+// ForDocument returns the metadata of a document
+func (r *MetaRepository) ForDocument(ctx context.Context, id int64) (map[string]string, error) {
+	meta, err := r.ForDocuments(ctx, []int64{id})
+	if err != nil || meta[id] == nil {
+		return map[string]string{}, err
+	}
+	return meta[id], nil
+}
+
+// Set sets a metadata value of a document
+func (r *MetaRepository) Set(ctx context.Context, id int64, key, value string) error {
+	_, err := r.q.ExecContext(ctx, `
+		INSERT INTO document_meta (document_id, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (document_id, key) DO UPDATE SET value = excluded.value`, id, key, value)
+	return err
+}
+
+// Delete removes a metadata key of a document
+func (r *MetaRepository) Delete(ctx context.Context, id int64, key string) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM document_meta WHERE document_id = ? AND key = ?", id, key)
+	return err
+}
+
+// RemoveDocument removes all metadata of a deleted document
+func (r *MetaRepository) RemoveDocument(ctx context.Context, id int64) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM document_meta WHERE document_id = ?", id)
+	return err
+}
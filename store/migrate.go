@@ -0,0 +1,109 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// libraryMigrations upgrade the schema of a library database. The number of
+// applied migrations is stored in PRAGMA user_version, so new migrations must
+// only ever be appended to this list.
+var libraryMigrations = []string{
+	// 1: unique (name, key) in config, keeping the most recent duplicate
+	`DELETE FROM config WHERE id NOT IN (SELECT MAX(id) FROM config GROUP BY name, key);
+	 CREATE UNIQUE INDEX IF NOT EXISTS idx_config_name_key ON config (name, key);`,
+	// 2: document timestamps and the indexes used by DocumentRepository.List
+	`CREATE TABLE IF NOT EXISTS documents (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT, content TEXT, parent_id INTEGER);
+	 ALTER TABLE documents ADD COLUMN created_at TEXT;
+	 ALTER TABLE documents ADD COLUMN updated_at TEXT;
+	 UPDATE documents SET title = COALESCE(title, ''),
+	   created_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now'),
+	   updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+	 CREATE INDEX IF NOT EXISTS idx_documents_parent_id ON documents (parent_id);
+	 CREATE INDEX IF NOT EXISTS idx_documents_title ON documents (title, id);
+	 CREATE INDEX IF NOT EXISTS idx_documents_updated_at ON documents (updated_at, id);`,
+	// 3: tags, many-to-many with documents
+	`CREATE TABLE IF NOT EXISTS tags (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL UNIQUE COLLATE NOCASE);
+	 CREATE TABLE IF NOT EXISTS document_tags (
+	   document_id INTEGER NOT NULL,
+	   tag_id INTEGER NOT NULL,
+	   PRIMARY KEY (document_id, tag_id)
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_document_tags_tag_id ON document_tags (tag_id);`,
+	// 4: key/value metadata of documents
+	`CREATE TABLE IF NOT EXISTS document_meta (
+	   document_id INTEGER NOT NULL,
+	   key TEXT NOT NULL,
+	   value TEXT NOT NULL,
+	   PRIMARY KEY (document_id, key)
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_document_meta_key_value ON document_meta (key, value);`,
+	// 5: links between documents, filled by backfillLinks
+	`CREATE TABLE IF NOT EXISTS links (
+	   source_id INTEGER NOT NULL,
+	   target_id INTEGER NOT NULL,
+	   PRIMARY KEY (source_id, target_id)
+	 );
+	 CREATE INDEX IF NOT EXISTS idx_links_target_id ON links (target_id);`,
+	// 6: wiki links in the links table, refilled by backfillLinks
+	`DELETE FROM links;`,
+	// 7: sort indexes on the expressions DocumentRepository.List orders by
+	`DROP INDEX IF EXISTS idx_documents_title;
+	 DROP INDEX IF EXISTS idx_documents_updated_at;
+	 CREATE INDEX IF NOT EXISTS idx_documents_title ON documents (COALESCE(title, ''), id);
+	 CREATE INDEX IF NOT EXISTS idx_documents_updated_at ON documents (COALESCE(updated_at, ''), id);`,
+}
+
+// migrationBackfills fill the tables of a migration from existing data where
+// SQL is not enough. They run in the transaction of the migration with the
+// same number.
+var migrationBackfills = map[int]func(tx *sql.Tx) error{
+	5: backfillLinks,
+	6: backfillLinks,
+}
+
+// Version returns the number of migrations applied to a library database
+func Version(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+// Migrate applies all pending migrations to a library database
+func Migrate(db *sql.DB) error {
+	version, err := Version(db)
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(libraryMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(libraryMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if backfill := migrationBackfills[i+1]; backfill != nil {
+			if err := backfill(tx); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d: %w", i+1, err)
+			}
+		}
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the schema version of a fully migrated library
+func SchemaVersion() int {
+	return len(libraryMigrations)
+}
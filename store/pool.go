@@ -0,0 +1,216 @@
+// Package store owns the library databases under the document root: the
+// shared connection pool, schema migrations and the repositories that hold
+// all SQL statements on documents and config.
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"main/metrics"
+)
+
+var (
+	// ErrLibraryUnavailable is returned to requests that were waiting on a
+	// library while it was renamed or deleted underneath them
+	ErrLibraryUnavailable = errors.New("library is no longer available")
+	// ErrLibraryArchived is returned when a write is attempted on an archived library
+	ErrLibraryArchived = errors.New("library is archived and read-only")
+	// ErrLibraryNotFound is returned for names that are not a library under the document root
+	ErrLibraryNotFound = errors.New("library not found")
+)
+
+// uriEscaper escapes the characters that would end the path of a SQLite URI filename
+var uriEscaper = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+
+// DSN returns the data source name of an existing library database.
+// mode=rw makes SQLite fail instead of creating a new empty database when
+// the file is missing.
+func DSN(libPath string) string {
+	return "file:" + uriEscaper.Replace(filepath.ToSlash(filepath.Join(libPath, "blog.db"))) + "?mode=rw"
+}
+
+// libraryEntry holds the shared database handle of a single library.
+// Regular requests hold the read lock for their whole duration, while
+// rename, archive and delete take the write lock so they wait for in-flight
+// requests to finish and can close the handle safely.
+type libraryEntry struct {
+	mu       sync.RWMutex
+	db       *sql.DB
+	archived bool
+	loaded   bool
+	closed   bool
+}
+
+// libraryPool caches one database handle per library directory, keyed by its
+// absolute path so libraries of the same name under different document roots
+// never share an entry
+type libraryPool struct {
+	mu      sync.Mutex
+	entries map[string]*libraryEntry
+}
+
+var pool = &libraryPool{entries: make(map[string]*libraryEntry)}
+
+// Acquire returns the shared database handle of a library together with a
+// release function that must be called once the caller is done with it.
+// Pass write as true for operations that modify the library.
+func Acquire(docRoot, name string, write bool) (*sql.DB, func(), error) {
+	return pool.acquire(docRoot, name, write)
+}
+
+// Exclusive waits for all users of a library to finish and keeps it closed
+// until the returned release function is called, see libraryPool.exclusive
+func Exclusive(docRoot, name string) func() {
+	return pool.exclusive(poolKey(docRoot, name))
+}
+
+// ExclusivePath is Exclusive for a library given by its directory, which
+// does not have to be under the document root
+func ExclusivePath(libPath string) func() {
+	return pool.exclusive(poolKey(libPath, ""))
+}
+
+// poolKey returns the absolute path of a library directory
+func poolKey(docRoot, name string) string {
+	path := filepath.Join(docRoot, name)
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func (p *libraryPool) entry(key string) *libraryEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.entries[key]
+	if !ok {
+		e = &libraryEntry{}
+		p.entries[key] = e
+	}
+	return e
+}
+
+// acquire returns the shared database handle of a library together with a
+// release function that must be called once the request is done with it.
+// When write is true the call fails for archived libraries.
+func (p *libraryPool) acquire(docRoot, name string, write bool) (*sql.DB, func(), error) {
+	// Unknown names never get an entry, so typos cannot pile up in the pool
+	if !ValidDir(name) || !Exists(docRoot, name) {
+		return nil, nil, ErrLibraryNotFound
+	}
+	e := p.entry(poolKey(docRoot, name))
+	e.mu.RLock()
+	if e.closed {
+		e.mu.RUnlock()
+		return nil, nil, ErrLibraryUnavailable
+	}
+
+	if !e.loaded {
+		// Upgrade to the write lock to open the database exactly once
+		e.mu.RUnlock()
+		e.mu.Lock()
+		if e.closed {
+			e.mu.Unlock()
+			return nil, nil, ErrLibraryUnavailable
+		}
+		if !e.loaded {
+			if err := e.open(docRoot, name); err != nil {
+				e.mu.Unlock()
+				// The library may have been removed since the check above
+				if !Exists(docRoot, name) {
+					return nil, nil, ErrLibraryNotFound
+				}
+				return nil, nil, err
+			}
+		}
+		e.mu.Unlock()
+		e.mu.RLock()
+		if e.closed {
+			e.mu.RUnlock()
+			return nil, nil, ErrLibraryUnavailable
+		}
+	}
+
+	if write && e.archived {
+		e.mu.RUnlock()
+		return nil, nil, ErrLibraryArchived
+	}
+	return e.db, e.mu.RUnlock, nil
+}
+
+// open connects to the library database, applies pending migrations and
+// loads its archived flag. The caller must hold the write lock.
+func (e *libraryEntry) open(docRoot, name string) error {
+	start := time.Now()
+	db := openInstrumented(DSN(filepath.Join(docRoot, name)), name)
+
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return err
+	}
+
+	var archived string
+	row := db.QueryRow("SELECT value FROM config WHERE name = 'blog' AND key = 'archived' LIMIT 1")
+	if err := row.Scan(&archived); err != nil && err != sql.ErrNoRows {
+		db.Close()
+		return err
+	}
+
+	e.db = db
+	e.archived = archived == "true"
+	e.loaded = true
+	metrics.ObserveDBOpen(name, time.Since(start))
+	return nil
+}
+
+// exclusive waits for all in-flight requests on a library to finish, closes
+// its database handle and blocks new requests until the returned release
+// function is called. Requests that were waiting in the meantime receive
+// ErrLibraryUnavailable, and the next request reopens the library from disk.
+func (p *libraryPool) exclusive(key string) func() {
+	e := p.entry(key)
+	e.mu.Lock()
+	if e.db != nil {
+		e.db.Close()
+		e.db = nil
+	}
+	e.closed = true
+
+	return func() {
+		p.mu.Lock()
+		if p.entries[key] == e {
+			delete(p.entries, key)
+		}
+		p.mu.Unlock()
+		e.mu.Unlock()
+	}
+}
+
+// ValidDir reports whether name can be used as a library directory
+// directly under the document root
+func ValidDir(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+	return !strings.ContainsAny(name, `/\`)
+}
+
+// CloseAll waits for in-flight requests on every cached library and
+// closes its database handle. It is called on server shutdown.
+func CloseAll() {
+	pool.mu.Lock()
+	keys := make([]string, 0, len(pool.entries))
+	for key := range pool.entries {
+		keys = append(keys, key)
+	}
+	pool.mu.Unlock()
+
+	for _, key := range keys {
+		pool.exclusive(key)()
+	}
+}
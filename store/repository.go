@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ErrNotFound is returned by the repositories when a row does not exist
+var ErrNotFound = errors.New("not found")
+
+// Querier is implemented by both *sql.DB and *sql.Tx, so the repositories
+// work inside and outside of transactions
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// InTx runs fn in a transaction on db, committing when it returns nil
+func InTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// inChunks calls fn for consecutive chunks of ids with the placeholders and
+// arguments of an IN (...) list, staying well below the SQLite limit on bound
+// parameters
+func inChunks(ids []int64, fn func(placeholders string, args []any) error) error {
+	for len(ids) > 0 {
+		chunk := ids[:min(len(ids), 500)]
+		ids = ids[len(chunk):]
+		args := make([]any, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		if err := fn("?"+strings.Repeat(", ?", len(chunk)-1), args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+
+	"main/models"
+)
+
+// TagRepository reads and writes the tags of a library's documents. Tag names
+// are compared case-insensitively, a tag keeps the case it was first added with.
+type TagRepository struct {
+	q Querier
+}
+
+// NewTagRepository returns a repository on a library database or transaction
+func NewTagRepository(q Querier) *TagRepository {
+	return &TagRepository{q: q}
+}
+
+// All returns every tag of the library with its document count, by name
+func (r *TagRepository) All(ctx context.Context) ([]models.Tag, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT t.name, count(*) FROM tags t JOIN document_tags dt ON dt.tag_id = t.id
+		GROUP BY t.id ORDER BY t.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// ForDocuments returns the tags of the given documents by document ID, each
+// list sorted by name. Documents without tags are left out.
+func (r *TagRepository) ForDocuments(ctx context.Context, ids []int64) (map[int64][]string, error) {
+	tags := make(map[int64][]string)
+	err := inChunks(ids, func(placeholders string, args []any) error {
+		rows, err := r.q.QueryContext(ctx, `
+			SELECT dt.document_id, t.name FROM document_tags dt JOIN tags t ON t.id = dt.tag_id
+			WHERE dt.document_id IN (`+placeholders+`) ORDER BY t.name`, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id int64
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				return err
+			}
+			tags[id] = append(tags[id], name)
+		}
+		return rows.Err()
+	})
+	return tags, err
+}
+
+// ForDocument returns the tags of a document, sorted by name
+func (r *TagRepository) ForDocument(ctx context.Context, id int64) ([]string, error) {
+	tags, err := r.ForDocuments(ctx, []int64{id})
+	if err != nil || tags[id] == nil {
+		return []string{}, err
+	}
+	return tags[id], nil
+}
+
+// Add tags a document, creating tags that do not exist yet
+func (r *TagRepository) Add(ctx context.Context, id int64, names []string) error {
+	for _, name := range names {
+		if _, err := r.q.ExecContext(ctx, "INSERT OR IGNORE INTO tags (name) VALUES (?)", name); err != nil {
+			return err
+		}
+		_, err := r.q.ExecContext(ctx, `
+			INSERT OR IGNORE INTO document_tags (document_id, tag_id)
+			SELECT ?, id FROM tags WHERE name = ?`, id, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove takes tags off a document. Tags no document carries any more are
+// deleted.
+func (r *TagRepository) Remove(ctx context.Context, id int64, names []string) error {
+	for _, name := range names {
+		_, err := r.q.ExecContext(ctx, `
+			DELETE FROM document_tags
+			WHERE document_id = ? AND tag_id = (SELECT id FROM tags WHERE name = ?)`, id, name)
+		if err != nil {
+			return err
+		}
+	}
+	return r.prune(ctx)
+}
+
+// RemoveDocument takes all tags off a deleted document
+func (r *TagRepository) RemoveDocument(ctx context.Context, id int64) error {
+	if _, err := r.q.ExecContext(ctx, "DELETE FROM document_tags WHERE document_id = ?", id); err != nil {
+		return err
+	}
+	return r.prune(ctx)
+}
+
+// prune deletes the tags without documents
+func (r *TagRepository) prune(ctx context.Context) error {
+	_, err := r.q.ExecContext(ctx, "DELETE FROM tags WHERE NOT EXISTS (SELECT 1 FROM document_tags WHERE tag_id = tags.id)")
+	return err
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"main/models"
+)
+
+// wikiLinkPattern matches [[Page Title]], [[#42]] and either with a label,
+// [[Page Title|label]]
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]|\n]+)(?:\|([^\[\]\n]+))?\]\]`)
+
+// ParseWikiLinks returns the sorted, distinct IDs named by the [[#id]] links
+// of content and whether it has any [[Page Title]] links
+func ParseWikiLinks(content string) (ids []int64, titles bool) {
+	for _, m := range wikiLinkPattern.FindAllStringSubmatch(content, -1) {
+		idText, ok := strings.CutPrefix(strings.TrimSpace(m[1]), "#")
+		if !ok {
+			titles = true
+			continue
+		}
+		if id, err := strconv.ParseInt(idText, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids), titles
+}
+
+// ResolveWikiLinks replaces the wiki links of content that name exactly one
+// of docs with /document?id= links. Titles match case-insensitively. Links
+// that match no document or several are left as they are. Every distinct
+// target is reported once, in order of appearance.
+func ResolveWikiLinks(content string, docs []models.DocumentRef) (string, []models.WikiLink) {
+	byID := make(map[int64]models.DocumentRef, len(docs))
+	byTitle := make(map[string][]models.DocumentRef)
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+		key := strings.ToLower(strings.TrimSpace(doc.Title))
+		byTitle[key] = append(byTitle[key], doc)
+	}
+
+	var links []models.WikiLink
+	reported := make(map[string]bool)
+	resolved := wikiLinkPattern.ReplaceAllStringFunc(content, func(match string) string {
+		m := wikiLinkPattern.FindStringSubmatch(match)
+		target, label := strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+
+		link := models.WikiLink{Target: target, Status: models.WikiLinkUnresolved}
+		var found []models.DocumentRef
+		if idText, ok := strings.CutPrefix(target, "#"); ok {
+			id, err := strconv.ParseInt(idText, 10, 64)
+			if doc, exists := byID[id]; err == nil && exists {
+				found = append(found, doc)
+			}
+		} else {
+			found = byTitle[strings.ToLower(target)]
+		}
+		switch {
+		case len(found) == 1:
+			link.Status = models.WikiLinkResolved
+			link.ID = found[0].ID
+		case len(found) > 1:
+			link.Status = models.WikiLinkAmbiguous
+			for _, doc := range found {
+				link.Candidates = append(link.Candidates, doc.ID)
+			}
+		}
+		if key := strings.ToLower(target); !reported[key] {
+			reported[key] = true
+			links = append(links, link)
+		}
+
+		if link.Status != models.WikiLinkResolved {
+			return match
+		}
+		if label == "" {
+			label = found[0].Title
+		}
+		if label == "" {
+			label = target
+		}
+		return fmt.Sprintf("[%s](/document?id=%d)", label, link.ID)
+	})
+	return resolved, links
+}
+
+// RewriteWikiLinks points the [[#id]] links of content for which link returns
+// a target at that target, keeping their labels. Wiki links cannot name a
+// library, so links to targets in another library become /document links
+// labeled with the target's title. [[Page Title]] links are left as they are.
+func RewriteWikiLinks(content string, link func(id int64) (LinkTarget, bool)) string {
+	return wikiLinkPattern.ReplaceAllStringFunc(content, func(match string) string {
+		m := wikiLinkPattern.FindStringSubmatch(match)
+		target, label := strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+		idText, ok := strings.CutPrefix(target, "#")
+		if !ok {
+			return match
+		}
+		id, err := strconv.ParseInt(idText, 10, 64)
+		if err != nil {
+			return match
+		}
+		to, ok := link(id)
+		if !ok {
+			return match
+		}
+
+		if to.Library == "" {
+			if label == "" {
+				return fmt.Sprintf("[[#%d]]", to.ID)
+			}
+			return fmt.Sprintf("[[#%d|%s]]", to.ID, label)
+		}
+		if label == "" {
+			label = to.Title
+		}
+		if label == "" {
+			label = target
+		}
+		return fmt.Sprintf("[%s](/document?library=%s&id=%d)", label, url.QueryEscape(to.Library), to.ID)
+	})
+}
@@ -0,0 +1,468 @@
+// Command genclient generates the typed Go client in package client from the
+// OpenAPI document in api/openapi.json. It understands the subset of OpenAPI
+// 3.0 used by that document. Run it through go generate in the client package.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+func main() {
+	specPath := flag.String("spec", "api/openapi.json", "OpenAPI document to read")
+	outPath := flag.String("out", "client/client.gen.go", "Go file to write")
+	pkg := flag.String("package", "client", "package name of the generated file")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var doc spec
+	if err := json.Unmarshal(data, &doc); err != nil {
+		log.Fatalf("%s: %v", *specPath, err)
+	}
+
+	g := &generator{spec: &doc}
+	src, err := g.generate(*pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*outPath, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// spec is the part of an OpenAPI document the generator reads
+type spec struct {
+	Paths      ordered[ordered[*operation]] `json:"paths"`
+	Components struct {
+		Parameters map[string]*parameter `json:"parameters"`
+		Schemas    ordered[*schema]      `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string         `json:"operationId"`
+	Summary     string         `json:"summary"`
+	Parameters  []*parameter   `json:"parameters"`
+	RequestBody *body          `json:"requestBody"`
+	Responses   ordered[*body] `json:"responses"`
+}
+
+type parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+// body is a request body or a response
+type body struct {
+	Ref     string             `json:"$ref"`
+	Content ordered[mediaType] `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string           `json:"$ref"`
+	Type                 string           `json:"type"`
+	Format               string           `json:"format"`
+	Description          string           `json:"description"`
+	Nullable             bool             `json:"nullable"`
+	Items                *schema          `json:"items"`
+	Properties           ordered[*schema] `json:"properties"`
+	Required             []string         `json:"required"`
+	AdditionalProperties *schema          `json:"additionalProperties"`
+}
+
+// ordered is a JSON object that remembers the order of its keys, so the
+// generated code follows the order of the document
+type ordered[T any] struct {
+	Keys   []string
+	Values map[string]T
+}
+
+func (o *ordered[T]) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	o.Values = make(map[string]T)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+		var value T
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		o.Keys = append(o.Keys, key)
+		o.Values[key] = value
+	}
+	return nil
+}
+
+type generator struct {
+	spec    *spec
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) generate(pkg string) ([]byte, error) {
+	g.imports = map[string]bool{"context": true, "net/url": true}
+
+	for _, name := range g.spec.Components.Schemas.Keys {
+		if err := g.schemaType(name, g.spec.Components.Schemas.Values[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	for _, path := range g.spec.Paths.Keys {
+		methods := g.spec.Paths.Values[path]
+		for _, method := range methods.Keys {
+			if err := g.operation(path, strings.ToUpper(method), methods.Values[method]); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by tools/genclient from api/openapi.json. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	var imports []string
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&out, "\t%q\n", imp)
+	}
+	out.WriteString(")\n")
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return out.Bytes(), fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+// schemaType writes the declaration of a named component schema
+func (g *generator) schemaType(name string, s *schema) error {
+	g.printf("\n")
+	if s.Description != "" {
+		g.printf("// %s is %s\n", name, lowerFirst(s.Description))
+	}
+	if s.Type != "object" || s.AdditionalProperties != nil || len(s.Properties.Keys) == 0 {
+		goType, err := g.goType(s)
+		if err != nil {
+			return err
+		}
+		g.printf("type %s %s\n", name, goType)
+		return nil
+	}
+
+	required := make(map[string]bool)
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	g.printf("type %s struct {\n", name)
+	for _, prop := range s.Properties.Keys {
+		ps := s.Properties.Values[prop]
+		goType, err := g.goType(ps)
+		if err != nil {
+			return fmt.Errorf("%s: %w", prop, err)
+		}
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+		if ps.Nullable {
+			goType = "*" + goType
+		}
+		if ps.Description != "" {
+			g.printf("\t// %s\n", ps.Description)
+		}
+		g.printf("\t%s %s `json:%q`\n", goName(prop), goType, tag)
+	}
+	g.printf("}\n")
+	return nil
+}
+
+// goType returns the Go type of a schema
+func (g *generator) goType(s *schema) (string, error) {
+	if s.Ref != "" {
+		return refName(s.Ref), nil
+	}
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return "time.Time", nil
+		case "binary":
+			return "[]byte", nil
+		}
+		return "string", nil
+	case "integer":
+		if s.Format == "int64" {
+			return "int64", nil
+		}
+		return "int", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := g.goType(s.Items)
+		return "[]" + item, err
+	case "object", "":
+		if s.AdditionalProperties != nil {
+			value, err := g.goType(s.AdditionalProperties)
+			return "map[string]" + value, err
+		}
+		if len(s.Properties.Keys) == 0 {
+			return "any", nil
+		}
+		return "", fmt.Errorf("inline objects with properties are not supported, use a component schema")
+	}
+	return "", fmt.Errorf("unsupported type %q", s.Type)
+}
+
+// operation writes the client method of a single operation
+func (g *generator) operation(path, method string, op *operation) error {
+	name := goName(op.OperationID)
+
+	// Required parameters become arguments, optional ones fields of a params struct
+	var args, optional []*parameter
+	for _, p := range op.Parameters {
+		if p.Ref != "" {
+			p = g.spec.Components.Parameters[refName(p.Ref)]
+		}
+		if p.Required || p.In == "path" {
+			args = append(args, p)
+		} else {
+			optional = append(optional, p)
+		}
+	}
+
+	if len(optional) > 0 {
+		g.printf("\n// %sParams holds the optional parameters of %s\n", name, name)
+		g.printf("type %sParams struct {\n", name)
+		for _, p := range optional {
+			goType, err := g.goType(p.Schema)
+			if err != nil {
+				return err
+			}
+			// Nullable parameters are pointers, so their zero value can be sent
+			if p.Schema.Nullable {
+				goType = "*" + goType
+			}
+			if p.Description != "" {
+				g.printf("\t// %s\n", p.Description)
+			}
+			g.printf("\t%s %s\n", goName(p.Name), goType)
+		}
+		g.printf("}\n")
+	}
+
+	signature := []string{"ctx context.Context"}
+	for _, p := range args {
+		goType, err := g.goType(p.Schema)
+		if err != nil {
+			return err
+		}
+		signature = append(signature, lowerFirst(goName(p.Name))+" "+goType)
+	}
+	if len(optional) > 0 {
+		signature = append(signature, "params *"+name+"Params")
+	}
+
+	// Request body
+	bodyKind := ""
+	if op.RequestBody != nil {
+		switch {
+		case op.RequestBody.Content.Values["application/json"].Schema != nil:
+			bodyKind = "json"
+			goType, err := g.goType(op.RequestBody.Content.Values["application/json"].Schema)
+			if err != nil {
+				return err
+			}
+			signature = append(signature, "body "+goType)
+		case op.RequestBody.Content.Values["multipart/form-data"].Schema != nil:
+			bodyKind = "multipart"
+			g.imports["io"] = true
+			signature = append(signature, "filename string", "file io.Reader")
+		default:
+			return fmt.Errorf("unsupported request body")
+		}
+	}
+
+	// Result of the first success response
+	result, resultKind := "", ""
+	for _, status := range op.Responses.Keys {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		content := op.Responses.Values[status].Content
+		if media, ok := content.Values["application/json"]; ok {
+			goType, err := g.goType(media.Schema)
+			if err != nil {
+				return err
+			}
+			if goType == "any" {
+				g.imports["encoding/json"] = true
+				result, resultKind = "json.RawMessage", "raw"
+			} else {
+				result, resultKind = goType, "json"
+			}
+		} else if len(content.Keys) > 0 {
+			result, resultKind = "[]byte", "raw"
+		}
+		break
+	}
+
+	g.printf("\n// %s sends %s %s", name, method, path)
+	if op.Summary != "" {
+		g.printf(":\n// %s", lowerFirst(op.Summary))
+	}
+	g.printf("\n")
+	if result != "" {
+		g.printf("func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(signature, ", "), result)
+	} else {
+		g.printf("func (c *Client) %s(%s) error {\n", name, strings.Join(signature, ", "))
+	}
+
+	// Path with escaped path parameters
+	pathExpr := fmt.Sprintf("%q", path)
+	for _, p := range args {
+		if p.In != "path" {
+			continue
+		}
+		pathExpr = strings.Replace(pathExpr, "{"+p.Name+"}", `" + url.PathEscape(`+g.toString(p, lowerFirst(goName(p.Name)))+`) + "`, 1)
+	}
+	pathExpr = strings.TrimSuffix(strings.TrimPrefix(pathExpr, `"" + `), ` + ""`)
+	g.printf("\tpath := %s\n", pathExpr)
+
+	g.printf("\tquery := url.Values{}\n")
+	for _, p := range args {
+		if p.In == "query" {
+			g.printf("\tquery.Set(%q, %s)\n", p.Name, g.toString(p, lowerFirst(goName(p.Name))))
+		}
+	}
+	if len(optional) > 0 {
+		g.printf("\tif params != nil {\n")
+		for _, p := range optional {
+			// Zero values are left out of the query
+			field := "params." + goName(p.Name)
+			// Arrays of strings are repeated parameters
+			if p.Schema.Type == "array" {
+				g.printf("\t\tfor _, v := range %s {\n\t\t\tquery.Add(%q, v)\n\t\t}\n", field, p.Name)
+				continue
+			}
+			cond := map[string]string{"string": field + ` != ""`, "integer": field + " != 0", "boolean": field}[p.Schema.Type]
+			value := field
+			if p.Schema.Nullable {
+				cond, value = field+" != nil", "*"+field
+			}
+			g.printf("\t\tif %s {\n\t\t\tquery.Set(%q, %s)\n\t\t}\n", cond, p.Name, g.toString(p, value))
+		}
+		g.printf("\t}\n")
+	}
+
+	var call string
+	switch bodyKind {
+	case "json":
+		call = fmt.Sprintf("c.doJSON(ctx, %q, path, query, body, %%s)", method)
+	case "multipart":
+		call = fmt.Sprintf("c.doMultipart(ctx, %q, path, query, filename, file, %%s)", method)
+	default:
+		call = fmt.Sprintf("c.doJSON(ctx, %q, path, query, nil, %%s)", method)
+	}
+
+	switch resultKind {
+	case "json":
+		g.printf("\tvar out %s\n", result)
+		g.printf("\terr := "+call+"\n", "&out")
+		g.printf("\treturn out, err\n")
+	case "raw":
+		g.printf("\tvar out []byte\n")
+		g.printf("\terr := "+call+"\n", "&out")
+		if result == "[]byte" {
+			g.printf("\treturn out, err\n")
+		} else {
+			g.printf("\treturn %s(out), err\n", result)
+		}
+	default:
+		g.printf("\treturn "+call+"\n", "nil")
+	}
+	g.printf("}\n")
+	return nil
+}
+
+// toString returns the expression formatting a parameter value as a string
+func (g *generator) toString(p *parameter, expr string) string {
+	switch p.Schema.Type {
+	case "integer":
+		g.imports["strconv"] = true
+		if p.Schema.Format == "int64" {
+			return "strconv.FormatInt(" + expr + ", 10)"
+		}
+		return "strconv.Itoa(" + expr + ")"
+	case "boolean":
+		g.imports["strconv"] = true
+		return "strconv.FormatBool(" + expr + ")"
+	}
+	return expr
+}
+
+// refName returns the name of the component a $ref points to
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// initialisms are written in upper case in Go names
+var initialisms = map[string]string{"id": "ID", "ids": "IDs", "api": "API", "url": "URL", "json": "JSON", "openapi": "OpenAPI"}
+
+// goName turns snake_case and camelCase names into exported Go names
+func goName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+		if upper, ok := initialisms[strings.ToLower(part)]; ok {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// lowerFirst lower-cases the first letter of s, or all of it when s is an
+// initialism such as ID
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	if strings.ToUpper(s) == s {
+		return strings.ToLower(s)
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,11 @@
+//go:build !embedui
+
+package web
+
+import "io/fs"
+
+// Embedded returns nil because the binary was built without the embedui tag.
+// Build the frontend into web/dist and rebuild with -tags embedui to embed it.
+func Embedded() fs.FS {
+	return nil
+}
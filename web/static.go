@@ -0,0 +1,125 @@
+package web
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"main/apierror"
+	"main/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hashedAsset matches the name segment before the extension that bundlers
+// put content hashes in, as in app.3f9a1c2b.js or index-BXk3kp9a.css
+var hashedAsset = regexp.MustCompile(`[.-]([A-Za-z0-9_-]{8,})\.[A-Za-z0-9]+$`)
+
+// precompressed lists the supported pre-compressed variants in order of preference
+var precompressed = []struct {
+	encoding  string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// Handler serves the frontend from fsys. Unknown paths outside /api fall back
+// to index.html so client-side routes work on reload, while unknown /api
+// paths still get a JSON 404.
+func Handler(fsys fs.FS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			apierror.Abort(c, middleware.GetRequestID(c), apierror.New(apierror.NotFound))
+			return
+		}
+		urlPath := c.Request.URL.Path
+		if urlPath == "/api" || strings.HasPrefix(urlPath, "/api/") {
+			apierror.Abort(c, middleware.GetRequestID(c), apierror.New(apierror.NotFound))
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+		if name == "" {
+			name = "index.html"
+		}
+		if !isFile(fsys, name) {
+			// Missing files with an extension are real 404s, everything
+			// else is a client-side route
+			if path.Ext(name) != "" {
+				c.Status(http.StatusNotFound)
+				return
+			}
+			name = "index.html"
+		}
+
+		serveFile(c, fsys, name)
+	}
+}
+
+// isHashedAsset reports whether a file name contains a content hash, so it
+// never changes once published. Hashes are told apart from words such as
+// app.bootstrap.css or favicon-original.png by their digits.
+func isHashedAsset(name string) bool {
+	m := hashedAsset.FindStringSubmatch(name)
+	return m != nil && strings.ContainsAny(m[1], "0123456789")
+}
+
+// isFile reports whether name is a regular file in fsys
+func isFile(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && !info.IsDir()
+}
+
+// serveFile writes name with cache headers, preferring a pre-compressed
+// variant the client accepts
+func serveFile(c *gin.Context, fsys fs.FS, name string) {
+	switch {
+	case name == "index.html":
+		c.Header("Cache-Control", "no-cache")
+	case isHashedAsset(path.Base(name)):
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	default:
+		c.Header("Cache-Control", "public, max-age=3600")
+	}
+
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	c.Header("Vary", "Accept-Encoding")
+
+	served := name
+	accepted := c.GetHeader("Accept-Encoding")
+	for _, variant := range precompressed {
+		if strings.Contains(accepted, variant.encoding) && isFile(fsys, name+variant.extension) {
+			served = name + variant.extension
+			c.Header("Content-Encoding", variant.encoding)
+			break
+		}
+	}
+
+	f, err := fsys.Open(served)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	// Both embed.FS and os.DirFS files support seeking, which enables range requests
+	if rs, ok := f.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, name, info.ModTime(), rs)
+		return
+	}
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, f)
+}